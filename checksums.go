@@ -0,0 +1,257 @@
+package goteafiles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// ChecksumSection holds the CRC32C checksum of the header and locates the
+// checksums of the item area, computed on blocks of BlockSize bytes. The
+// block checksums, one uint32 per block, are written after the item area
+// at TableOffset, and the blocks cover everything from ItemStart to
+// TableOffset, including the chunk index of block compressed files. The
+// header checksum is computed with HeaderChecksum set to zero. The
+// section has a fixed size so that it can be updated in place when the
+// file is closed.
+type ChecksumSection struct {
+	Algorithm      int32
+	BlockSize      int32
+	HeaderChecksum uint32
+	BlockCount     int64
+	TableOffset    int64
+}
+
+const defaultChecksumBlockSize = 64 * 1024
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+func (cs *ChecksumSection) ID() int32 {
+	return CHECKSUM_SECTION_ID
+}
+
+func (cs *ChecksumSection) Read(r io.Reader, order binary.ByteOrder) error {
+	err := binary.Read(r, order, cs)
+	if err != nil { return err }
+	if cs.BlockSize <= 0 {
+		return fmt.Errorf("checksum blocks of %d bytes: %w", cs.BlockSize, ErrCorrupt)
+	}
+	return nil
+}
+
+func (cs *ChecksumSection) Write(w io.Writer, order binary.ByteOrder) error {
+	return binary.Write(w, order, cs)
+}
+
+func (cs *ChecksumSection) Size() int64 {
+	return int64(binary.Size(cs))
+}
+
+// WithChecksums stores CRC32C checksums of the header and of the item
+// area, in blocks of blockSize bytes, or 64 KiB if blockSize is zero. A
+// multiple of the item size keeps each item in a single block. The
+// checksums are completed when the file is closed, so the file must be
+// written to a seekable writer. Use Verify to check them.
+func WithChecksums(blockSize int) TeaFileConfig {
+	return configFunc(func (tf *TeaFile) error {
+		if blockSize == 0 {
+			blockSize = defaultChecksumBlockSize
+		}
+		if blockSize < 0 {
+			return fmt.Errorf("invalid checksum block size %d", blockSize)
+		}
+		tf.checksumSection = &ChecksumSection{
+			Algorithm: CHECKSUM_CRC32C,
+			BlockSize: int32(blockSize),
+		}
+		return nil
+	})
+}
+
+// checksumWriter computes the block checksums of the bytes written after
+// the header
+type checksumWriter struct {
+	w       io.Writer
+	section *ChecksumSection
+	offset  int64
+	crc     uint32
+	pending int64
+	sums    []uint32
+}
+
+// startChecksums wraps the writer of a new or reopened file positioned at
+// offset. The last block checksum of a reopened file is resumed, as a
+// CRC can be updated with more bytes.
+func (tf *TeaFile) startChecksums(offset int64, sums []uint32) error {
+	if tf.checksumSection == nil {
+		return nil
+	}
+	if tf.seeker == nil {
+		return fmt.Errorf("checksums: %w", ErrNotSeekable)
+	}
+	cw := &checksumWriter{
+		w: tf.writer,
+		section: tf.checksumSection,
+		offset: offset,
+		sums: sums,
+	}
+	if pending := (offset - tf.header.ItemStart) % int64(cw.section.BlockSize); pending != 0 {
+		if len(sums) == 0 {
+			return fmt.Errorf("no checksum for the last block: %w", ErrCorrupt)
+		}
+		cw.pending = pending
+		cw.crc = sums[len(sums) - 1]
+		cw.sums = sums[:len(sums) - 1]
+	}
+	tf.checksumWriter = cw
+	tf.writer = cw
+	return nil
+}
+
+func (cw *checksumWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	b := p[:n]
+	for len(b) > 0 {
+		size := int64(cw.section.BlockSize) - cw.pending
+		if size > int64(len(b)) {
+			size = int64(len(b))
+		}
+		cw.crc = crc32.Update(cw.crc, crc32c, b[:size])
+		cw.pending += size
+		if cw.pending == int64(cw.section.BlockSize) {
+			cw.sums = append(cw.sums, cw.crc)
+			cw.crc = 0
+			cw.pending = 0
+		}
+		b = b[size:]
+	}
+	cw.offset += int64(n)
+	return n, err
+}
+
+// finish writes the checksum table after the written bytes and gives the
+// TeaFile its writer back. The header checksum is computed once the rest
+// of the header is complete.
+func (cw *checksumWriter) finish(tf *TeaFile) error {
+	if cw.pending != 0 {
+		cw.sums = append(cw.sums, cw.crc)
+	}
+	tf.writer = cw.w
+	if tf.chunkSection == nil {
+		tf.header.ItemEnd = cw.offset
+	}
+	cw.section.TableOffset = cw.offset
+	cw.section.BlockCount = int64(len(cw.sums))
+	return binary.Write(tf.writer, nativeEndian, cw.sums)
+}
+
+// headerChecksum returns the checksum of the header, computed with the
+// header checksum set to zero
+func (tf *TeaFile) headerChecksum() (uint32, error) {
+	tf.checksumSection.HeaderChecksum = 0
+	var buf bytes.Buffer
+	err := tf.encodeHeader(&buf)
+	if err != nil { return 0, err }
+	return crc32.Checksum(buf.Bytes(), crc32c), nil
+}
+
+// headerChecksumOffset returns the position of the header checksum in
+// the file
+func (tf *TeaFile) headerChecksumOffset() int64 {
+	offset := headerSize
+	for _, s := range tf.sections() {
+		if s.ID() == CHECKSUM_SECTION_ID {
+			break
+		}
+		offset += sectionsSize([]Section{s})
+	}
+	// Section ID, next section offset, algorithm and block size
+	return offset + 16
+}
+
+// readChecksums reads the block checksums of the file
+func (tf *TeaFile) readChecksums() ([]uint32, error) {
+	if tf.checksumSection.BlockCount < 0 || tf.checksumSection.TableOffset < tf.header.ItemStart {
+		return nil, fmt.Errorf("%d checksums at offset %d: %w", tf.checksumSection.BlockCount, tf.checksumSection.TableOffset, ErrCorrupt)
+	}
+	if tf.size != nil {
+		size, err := tf.size()
+		if err != nil { return nil, err }
+		if tf.checksumSection.BlockCount > (size - tf.checksumSection.TableOffset) / 4 {
+			return nil, fmt.Errorf("reading checksums: %w", io.ErrUnexpectedEOF)
+		}
+	}
+	_, err := tf.seeker.Seek(tf.checksumSection.TableOffset, io.SeekStart)
+	if err != nil { return nil, err }
+	sums := make([]uint32, tf.checksumSection.BlockCount)
+	err = binary.Read(tf.reader, nativeEndian, sums)
+	if err != nil { return nil, fmt.Errorf("reading checksums: %w", unexpected(err)) }
+	return sums, nil
+}
+
+// Verify checks the header and item area of the file against its
+// checksums, returning a *ChecksumError for the first corrupt block. The
+// read position is left unchanged.
+func (tf *TeaFile) Verify() (err error) {
+	if tf.mode == os.O_WRONLY {
+		return fmt.Errorf("verifying in write mode: %w", ErrWrongMode)
+	}
+	if tf.checksumSection == nil {
+		return ErrNoChecksumSection
+	}
+	if tf.checksumSection.Algorithm != CHECKSUM_CRC32C {
+		return fmt.Errorf("unsupported checksum algorithm %d", tf.checksumSection.Algorithm)
+	}
+	if tf.seeker == nil {
+		return ErrNotSeekable
+	}
+	pos, err := tf.seeker.Seek(0, io.SeekCurrent)
+	if err != nil { return err }
+	defer func() {
+		_, serr := tf.seeker.Seek(pos, io.SeekStart)
+		if err == nil {
+			err = serr
+		}
+	}()
+
+	_, err = tf.seeker.Seek(0, io.SeekStart)
+	if err != nil { return err }
+	header := make([]byte, tf.header.ItemStart)
+	_, err = io.ReadFull(tf.reader, header)
+	if err != nil { return truncated(err) }
+	offset := tf.headerChecksumOffset()
+	if offset + 4 > int64(len(header)) {
+		return fmt.Errorf("header checksum at offset %d after ItemStart %d: %w", offset, len(header), ErrCorrupt)
+	}
+	copy(header[offset:offset + 4], []byte{0, 0, 0, 0})
+	if crc32.Checksum(header, crc32c) != tf.checksumSection.HeaderChecksum {
+		return &ChecksumError{Block: -1, Offset: 0, Size: tf.header.ItemStart}
+	}
+
+	sums, err := tf.readChecksums()
+	if err != nil { return err }
+	blockSize := int64(tf.checksumSection.BlockSize)
+	area := tf.checksumSection.TableOffset - tf.header.ItemStart
+	if count := (area + blockSize - 1) / blockSize; count != int64(len(sums)) {
+		return fmt.Errorf("%d checksums for %d blocks", len(sums), count)
+	}
+	_, err = tf.seeker.Seek(tf.header.ItemStart, io.SeekStart)
+	if err != nil { return err }
+	block := make([]byte, blockSize)
+	for i, sum := range sums {
+		offset := tf.header.ItemStart + int64(i) * blockSize
+		b := block
+		if remaining := tf.checksumSection.TableOffset - offset; remaining < blockSize {
+			b = block[:remaining]
+		}
+		_, err = io.ReadFull(tf.reader, b)
+		if err != nil { return unexpected(err) }
+		if crc32.Checksum(b, crc32c) != sum {
+			return &ChecksumError{Block: int64(i), Offset: offset, Size: int64(len(b))}
+		}
+	}
+	return nil
+}
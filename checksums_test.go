@@ -0,0 +1,160 @@
+package goteafiles
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeChecksummedTicks(t *testing.T, start time.Time, from int, to int, configs ...TeaFileConfig) *TeaFile {
+	ts := TimeSection{Epoch: 719162, TicksPerDay: 86400000}
+	var tf *TeaFile
+	var err error
+	if from == 0 {
+		tf, err = Create("test.tea", configs...)
+	} else {
+		tf, err = OpenWrite("test.tea", reflect.TypeOf(Tick{}))
+	}
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	for i := from; i < to; i++ {
+		err = tf.Write(Tick{Time: ts.Ticks(start.Add(time.Duration(i) * time.Second)), Price: float64(i)})
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	tf, err = OpenRead("test.tea", reflect.TypeOf(Tick{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	return tf
+}
+
+func TestChecksums(t *testing.T) {
+	start := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	configs := []TeaFileConfig{
+		WithDataType(reflect.TypeOf(Tick{})),
+		WithTimeFields(719162, 86400000, []int32{0}),
+		WithChecksums(160),
+	}
+	tf := writeChecksummedTicks(t, start, 0, 995, configs...)
+	if err := tf.Verify(); err != nil {
+		t.Fatalf("error verifying TeaFile: %v", err)
+	}
+	_ = tf.Close()
+
+	// Appending keeps the checksums, resuming the last partial block
+	tf = writeChecksummedTicks(t, start, 995, 1000)
+	if err := tf.Verify(); err != nil {
+		t.Fatalf("error verifying TeaFile: %v", err)
+	}
+	n, err := tf.ItemCount()
+	if err != nil || n != 1000 {
+		t.Fatalf("got %d items, was expecting 1000: %v", n, err)
+	}
+	for i := 0; i < 1000; i++ {
+		val, err := tf.Read()
+		if err != nil {
+			t.Fatalf("error reading item %d: %v", i, err)
+		}
+		if tick := val.(reflect.Value).Elem().Interface().(Tick); tick.Price != float64(i) {
+			t.Fatalf("got wrong item %d: %v", i, tick)
+		}
+	}
+	// The checksum table is not read as items
+	if _, err := tf.Read(); err != io.EOF {
+		t.Fatalf("was expecting io.EOF, got %v", err)
+	}
+	itemStart := tf.header.ItemStart
+	_ = tf.Close()
+
+	// Flip a bit in item 333, held by block 33
+	data, err := os.ReadFile("test.tea")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	data[itemStart + 333 * 16 + 9] ^= 1
+	tf, err = OpenReadBytes(data, reflect.TypeOf(Tick{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	err = tf.Verify()
+	var cerr *ChecksumError
+	if !errors.As(err, &cerr) || cerr.Block != 33 || cerr.Offset != itemStart + 33 * 160 {
+		t.Fatalf("was expecting a checksum error on block 33, got %v", err)
+	}
+	if !errors.Is(err, ErrChecksum) {
+		t.Fatalf("was expecting ErrChecksum, got %v", err)
+	}
+	data[itemStart + 333 * 16 + 9] ^= 1
+
+	// Corrupt the header
+	data[8 + 4] ^= 1
+	tf, err = OpenReadBytes(data, reflect.TypeOf(Tick{}))
+	if err == nil {
+		err = tf.Verify()
+	}
+	if err == nil {
+		t.Fatalf("was expecting an error for a corrupt header")
+	}
+	data[8 + 4] ^= 1
+
+	// ItemStart inside the sections or after the end of the file
+	for _, start := range []int64{32, 1 << 40} {
+		corrupt := append([]byte{}, data...)
+		nativeEndian.PutUint64(corrupt[8:], uint64(start))
+		if _, err := OpenReadBytes(corrupt, reflect.TypeOf(Tick{})); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("ItemStart %d: was expecting ErrCorrupt, got %v", start, err)
+		}
+	}
+
+	// Truncated copy
+	tf, err = OpenReadBytes(data[:len(data) - 10], reflect.TypeOf(Tick{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	if err = tf.Verify(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("was expecting io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	// A partial last block without checksum cannot be resumed
+	tf = writeChecksummedTicks(t, start, 0, 995, configs...)
+	offset := tf.headerChecksumOffset() + 4
+	_ = tf.Close()
+	data, err = os.ReadFile("test.tea")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	nativeEndian.PutUint64(data[offset:], 0)
+	err = os.WriteFile("test.tea", data, 0666)
+	if err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	if _, err := OpenWrite("test.tea", reflect.TypeOf(Tick{})); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("was expecting ErrCorrupt, got %v", err)
+	}
+
+	// Checksums of block compressed files cover the chunks and their index
+	configs = append(configs, WithChunkCompression(Zstd, 100))
+	tf = writeChecksummedTicks(t, start, 0, 1000, configs...)
+	if err := tf.Verify(); err != nil {
+		t.Fatalf("error verifying TeaFile: %v", err)
+	}
+	n, err = tf.ItemCount()
+	if err != nil || n != 1000 {
+		t.Fatalf("got %d items, was expecting 1000: %v", n, err)
+	}
+	_ = tf.Close()
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+}
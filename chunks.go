@@ -0,0 +1,349 @@
+package goteafiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ChunkSection describes the item area of a block compressed TeaFile.
+// Items are compressed in chunks of ChunkItems items, each chunk being
+// written as its compressed size on 4 bytes followed by the compressed
+// bytes. The chunks span the item area, from ItemStart to ItemEnd, and
+// are followed by the chunk index: the offset and compressed size of each
+// chunk, as two int64. The section has a fixed size so that it can be
+// updated in place when the file is closed, which is why it locates the
+// index rather than holding it: the chunk count is only known once every
+// item is written.
+type ChunkSection struct {
+	Compression int32
+	ChunkItems  int32
+	ItemCount   int64
+	ChunkCount  int64
+	IndexOffset int64
+}
+
+// chunkEntry locates a chunk in the file
+type chunkEntry struct {
+	Offset int64
+	Size   int64
+}
+
+func (cs *ChunkSection) ID() int32 {
+	return CHUNK_SECTION_ID
+}
+
+func (cs *ChunkSection) Read(r io.Reader, order binary.ByteOrder) error {
+	err := binary.Read(r, order, cs)
+	if err != nil { return err }
+	if cs.ChunkItems <= 0 || cs.ItemCount < 0 || cs.IndexOffset < 0 {
+		return fmt.Errorf("%d items in chunks of %d, index at %d: %w", cs.ItemCount, cs.ChunkItems, cs.IndexOffset, ErrCorrupt)
+	}
+	chunks := cs.ItemCount / int64(cs.ChunkItems)
+	if cs.ItemCount % int64(cs.ChunkItems) != 0 {
+		chunks += 1
+	}
+	if cs.ChunkCount != chunks {
+		return fmt.Errorf("%d chunks for %d items in chunks of %d: %w", cs.ChunkCount, cs.ItemCount, cs.ChunkItems, ErrCorrupt)
+	}
+	return nil
+}
+
+func (cs *ChunkSection) Write(w io.Writer, order binary.ByteOrder) error {
+	return binary.Write(w, order, cs)
+}
+
+func (cs *ChunkSection) Size() int64 {
+	return int64(binary.Size(cs))
+}
+
+// WithChunkCompression compresses the items in chunks of chunkItems items,
+// indexed so that SeekItem and SeekTime only decompress the chunk holding
+// the item. The file must be written to a seekable writer, as the header
+// is completed when the file is closed.
+func WithChunkCompression(c Compression, chunkItems int) TeaFileConfig {
+	return configFunc(func (tf *TeaFile) error {
+		if c != Gzip && c != Zstd {
+			return fmt.Errorf("unsupported chunk compression %v", c)
+		}
+		if chunkItems <= 0 {
+			return fmt.Errorf("chunks must hold at least one item, got %d", chunkItems)
+		}
+		tf.chunkSection = &ChunkSection{
+			Compression: int32(c),
+			ChunkItems: int32(chunkItems),
+		}
+		return nil
+	})
+}
+
+// chunkCodec compresses and decompresses whole chunks, of at most
+// maxSize bytes once decompressed when maxSize is not zero
+type chunkCodec struct {
+	compression Compression
+	maxSize     int64
+	encoder     *zstd.Encoder
+	decoder     *zstd.Decoder
+}
+
+func newChunkCodec(c Compression, maxSize int64) (*chunkCodec, error) {
+	codec := &chunkCodec{compression: c, maxSize: maxSize}
+	switch c {
+	case NoCompression, Gzip:
+	case Zstd:
+		var err error
+		codec.encoder, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil { return nil, err }
+		codec.decoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil { return nil, err }
+	default:
+		return nil, fmt.Errorf("unsupported chunk compression %v", c)
+	}
+	return codec, nil
+}
+
+func (cc *chunkCodec) compress(src []byte) ([]byte, error) {
+	switch cc.compression {
+	case NoCompression:
+		return src, nil
+	case Zstd:
+		return cc.encoder.EncodeAll(src, nil), nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(src)
+	if err != nil { return nil, err }
+	err = zw.Close()
+	return buf.Bytes(), err
+}
+
+func (cc *chunkCodec) decompress(dst []byte, src []byte) ([]byte, error) {
+	switch cc.compression {
+	case NoCompression:
+		return src, nil
+	case Zstd:
+		// The content size of the frame is allocated before decoding
+		var h zstd.Header
+		if cc.maxSize > 0 && h.Decode(src) == nil && h.HasFCS && h.FrameContentSize > uint64(cc.maxSize) {
+			return nil, fmt.Errorf("chunk of %d bytes, more than %d: %w", h.FrameContentSize, cc.maxSize, ErrCorrupt)
+		}
+		return cc.decoder.DecodeAll(src, dst[:0])
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil { return nil, err }
+	if cc.maxSize == 0 {
+		return ioutil.ReadAll(zr)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(zr, cc.maxSize + 1))
+	if err != nil { return nil, err }
+	if int64(len(data)) > cc.maxSize {
+		return nil, fmt.Errorf("chunk of more than %d bytes: %w", cc.maxSize, ErrCorrupt)
+	}
+	return data, nil
+}
+
+// chunkWriter accumulates items and writes them as compressed chunks,
+// encoding them first if the file has an encoding section
+type chunkWriter struct {
+	section *ChunkSection
+	codec   *chunkCodec
+	items   *itemCodec
+	buf     []byte
+	encoded []byte
+	pending int
+	offset  int64
+	index   []chunkEntry
+}
+
+func newChunkWriter(tf *TeaFile, offset int64) (*chunkWriter, error) {
+	codec, err := newChunkCodec(Compression(tf.chunkSection.Compression), 0)
+	if err != nil { return nil, err }
+	cw := &chunkWriter{
+		section: tf.chunkSection,
+		codec: codec,
+		offset: offset,
+	}
+	if tf.encodingSection != nil {
+		cw.items, err = newItemCodec(tf.itemSection, tf.encodingSection)
+		if err != nil { return nil, err }
+	}
+	return cw, nil
+}
+
+func (cw *chunkWriter) writeItem(w io.Writer, b []byte) error {
+	cw.buf = append(cw.buf, b...)
+	cw.pending += 1
+	cw.section.ItemCount += 1
+	if cw.pending == int(cw.section.ChunkItems) {
+		return cw.flush(w)
+	}
+	return nil
+}
+
+func (cw *chunkWriter) flush(w io.Writer) error {
+	if cw.pending == 0 {
+		return nil
+	}
+	data := cw.buf
+	if cw.items != nil {
+		cw.encoded = cw.items.encode(cw.encoded[:0], cw.buf)
+		data = cw.encoded
+	}
+	compressed, err := cw.codec.compress(data)
+	if err != nil { return err }
+	err = binary.Write(w, nativeEndian, int32(len(compressed)))
+	if err != nil { return err }
+	_, err = w.Write(compressed)
+	if err != nil { return err }
+	cw.index = append(cw.index, chunkEntry{Offset: cw.offset, Size: int64(len(compressed))})
+	cw.offset += 4 + int64(len(compressed))
+	cw.buf = cw.buf[:0]
+	cw.pending = 0
+	return nil
+}
+
+// finish writes the pending items and the chunk index, and completes the
+// chunk section
+func (cw *chunkWriter) finish(tf *TeaFile) error {
+	err := cw.flush(tf.writer)
+	if err != nil { return err }
+	err = binary.Write(tf.writer, nativeEndian, cw.index)
+	if err != nil { return err }
+	tf.header.ItemEnd = cw.offset
+	cw.section.IndexOffset = cw.offset
+	cw.section.ChunkCount = int64(len(cw.index))
+	return nil
+}
+
+// chunkReader reads the items of a block compressed file. With an index
+// the chunks are read in any order, without one they are read as a stream.
+type chunkReader struct {
+	section  *ChunkSection
+	codec    *chunkCodec
+	items    *itemCodec
+	itemSize int64
+	index    []chunkEntry
+	chunk    int64
+	offset   int64
+	end      int64
+	buf      []byte
+	data     []byte
+	item     int64
+}
+
+// newChunkReader loads the chunk index when the reader supports seeking
+func newChunkReader(tf *TeaFile) (*chunkReader, error) {
+	// A chunk holds at most ChunkItems items, each field of encoded items
+	// taking at most a varint
+	items := int64(tf.chunkSection.ChunkItems)
+	if tf.chunkSection.ItemCount < items {
+		items = tf.chunkSection.ItemCount
+	}
+	itemSize := int64(tf.itemSection.Info.ItemSize)
+	if tf.encodingSection != nil {
+		itemSize += int64(binary.MaxVarintLen64 * len(tf.itemSection.Fields))
+	}
+	codec, err := newChunkCodec(Compression(tf.chunkSection.Compression), items * itemSize)
+	if err != nil { return nil, err }
+	cr := &chunkReader{
+		section: tf.chunkSection,
+		codec: codec,
+		itemSize: int64(tf.itemSection.Info.ItemSize),
+		chunk: -1,
+		offset: tf.header.ItemStart,
+		end: tf.header.ItemEnd,
+	}
+	if tf.encodingSection != nil {
+		cr.items, err = newItemCodec(tf.itemSection, tf.encodingSection)
+		if err != nil { return nil, err }
+	}
+	if tf.seeker == nil {
+		return cr, nil
+	}
+	// The index lies between the chunks and the end of the file
+	size := tf.chunkSection.IndexOffset + 16 * tf.chunkSection.ChunkCount
+	if tf.size != nil {
+		size, err = tf.size()
+		if err != nil { return nil, err }
+	}
+	if tf.chunkSection.ChunkCount > 0 && (tf.chunkSection.IndexOffset < tf.header.ItemStart || tf.chunkSection.ChunkCount > (size - tf.chunkSection.IndexOffset) / 16) {
+		return nil, fmt.Errorf("chunk index of %d chunks at offset %d in %d bytes: %w", tf.chunkSection.ChunkCount, tf.chunkSection.IndexOffset, size, ErrCorrupt)
+	}
+	_, err = tf.seeker.Seek(tf.chunkSection.IndexOffset, io.SeekStart)
+	if err != nil { return nil, err }
+	cr.index = make([]chunkEntry, tf.chunkSection.ChunkCount)
+	err = binary.Read(tf.reader, nativeEndian, cr.index)
+	if err != nil { return nil, fmt.Errorf("reading chunk index: %v", truncated(err)) }
+	_, err = tf.seeker.Seek(tf.header.ItemStart, io.SeekStart)
+	return cr, err
+}
+
+// seek positions the reader at item idx, which may be the item count
+func (cr *chunkReader) seek(idx int64) error {
+	if idx < 0 || idx > cr.section.ItemCount {
+		return fmt.Errorf("seeking item %d of %d", idx, cr.section.ItemCount)
+	}
+	cr.item = idx
+	return nil
+}
+
+func (cr *chunkReader) readItem(tf *TeaFile, b []byte) error {
+	if cr.item >= cr.section.ItemCount {
+		return io.EOF
+	}
+	chunk := cr.item / int64(cr.section.ChunkItems)
+	if chunk != cr.chunk {
+		err := cr.load(tf, chunk)
+		if err != nil { return err }
+	}
+	offset := (cr.item - chunk * int64(cr.section.ChunkItems)) * cr.itemSize
+	if offset + cr.itemSize > int64(len(cr.data)) {
+		return fmt.Errorf("chunk %d holds too few items", chunk)
+	}
+	copy(b, cr.data[offset:offset + cr.itemSize])
+	cr.item += 1
+	return nil
+}
+
+func (cr *chunkReader) load(tf *TeaFile, chunk int64) error {
+	if chunk < 0 {
+		return fmt.Errorf("loading chunk %d", chunk)
+	}
+	if cr.index != nil {
+		if chunk >= int64(len(cr.index)) {
+			return io.EOF
+		}
+		cr.offset = cr.index[chunk].Offset
+		_, err := tf.seeker.Seek(cr.offset, io.SeekStart)
+		if err != nil { return err }
+	} else if chunk != cr.chunk + 1 {
+		return ErrNotSeekable
+	}
+	var size int32
+	err := binary.Read(tf.reader, nativeEndian, &size)
+	if err != nil { return unexpected(err) }
+	// Chunks lie between ItemStart and ItemEnd
+	if size < 0 || int64(size) > cr.end - cr.offset - 4 {
+		return fmt.Errorf("chunk %d of %d bytes at offset %d, items end at %d: %w", chunk, size, cr.offset, cr.end, ErrCorrupt)
+	}
+	cr.offset += 4 + int64(size)
+	compressed := make([]byte, size)
+	_, err = io.ReadFull(tf.reader, compressed)
+	if err != nil { return unexpected(err) }
+	if cr.items == nil {
+		cr.data, err = cr.codec.decompress(cr.data, compressed)
+		if err != nil { return err }
+	} else {
+		cr.buf, err = cr.codec.decompress(cr.buf, compressed)
+		if err != nil { return err }
+		cr.data, err = cr.items.decode(cr.data[:0], cr.buf)
+		if err != nil { return err }
+	}
+	cr.chunk = chunk
+	return nil
+}
@@ -0,0 +1,189 @@
+package goteafiles
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Tick struct {
+	Time  int64
+	Price float64
+}
+
+func TestChunkCompression(t *testing.T) {
+	ts := TimeSection{Epoch: 719162, TicksPerDay: 86400000}
+	start := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	for _, c := range []Compression{Gzip, Zstd} {
+		tf, err := Create(
+			"test.tea",
+			WithDataType(reflect.TypeOf(Tick{})),
+			WithTimeFields(ts.Epoch, ts.TicksPerDay, []int32{0}),
+			WithChunkCompression(c, 64))
+		if err != nil {
+			t.Fatalf("%v: error creating TeaFile: %v", c, err)
+		}
+		for i := 0; i < 1000; i++ {
+			tick := Tick{
+				Time: ts.Ticks(start.Add(time.Duration(i) * time.Second)),
+				Price: 100 + float64(i % 10) / 100,
+			}
+			err = tf.Write(tick)
+			if err != nil {
+				t.Fatalf("%v: error writing data to TeaFile: %v", c, err)
+			}
+		}
+		err = tf.Close()
+		if err != nil {
+			t.Fatalf("%v: error closing TeaFile: %v", c, err)
+		}
+		fi, err := os.Stat("test.tea")
+		if err != nil {
+			t.Fatalf("%v: error reading file size: %v", c, err)
+		}
+		if fi.Size() > 1000 * 16 / 2 {
+			t.Fatalf("%v: file is too large: %d bytes", c, fi.Size())
+		}
+
+		tf, err = OpenRead("test.tea", reflect.TypeOf(Tick{}))
+		if err != nil {
+			t.Fatalf("%v: error opening TeaFile: %v", c, err)
+		}
+		n, err := tf.ItemCount()
+		if err != nil || n != 1000 {
+			t.Fatalf("%v: got %d items, was expecting 1000: %v", c, n, err)
+		}
+		for i := 0; i < 1000; i++ {
+			val, err := tf.Read()
+			if err != nil {
+				t.Fatalf("%v: error reading item %d: %v", c, i, err)
+			}
+			tick := val.(reflect.Value).Elem().Interface().(Tick)
+			if ts.Time(tick.Time) != start.Add(time.Duration(i) * time.Second) {
+				t.Fatalf("%v: got wrong item %d: %v", c, i, tick)
+			}
+		}
+		if _, err := tf.Read(); err != io.EOF {
+			t.Fatalf("%v: was expecting io.EOF, got %v", c, err)
+		}
+
+		err = tf.SeekItem(700)
+		if err != nil {
+			t.Fatalf("%v: error seeking item: %v", c, err)
+		}
+		val, err := tf.Read()
+		if err != nil {
+			t.Fatalf("%v: error reading data: %v", c, err)
+		}
+		if tick := val.(reflect.Value).Elem().Interface().(Tick); ts.Time(tick.Time) != start.Add(700 * time.Second) {
+			t.Fatalf("%v: got wrong item after seek: %v", c, tick)
+		}
+		for _, idx := range []int64{-1, 1001} {
+			if err := tf.SeekItem(idx); err == nil {
+				t.Fatalf("%v: was expecting an error seeking item %d", c, idx)
+			}
+		}
+
+		idx, err := tf.SeekTime(start.Add(321500 * time.Millisecond))
+		if err != nil || idx != 322 {
+			t.Fatalf("%v: got index %d, was expecting 322: %v", c, idx, err)
+		}
+		err = tf.Close()
+		if err != nil {
+			t.Fatalf("%v: error closing TeaFile: %v", c, err)
+		}
+
+		// Chunks can be decoded from a stream too
+		f, err := os.Open("test.tea")
+		if err != nil {
+			t.Fatalf("%v: error opening file: %v", c, err)
+		}
+		d, err := NewDecoder(struct{ io.Reader }{f}, reflect.TypeOf(Tick{}))
+		if err != nil {
+			t.Fatalf("%v: error decoding header: %v", c, err)
+		}
+		count := 0
+		for {
+			var tick Tick
+			err := d.Decode(&tick)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%v: error decoding item %d: %v", c, count, err)
+			}
+			count += 1
+		}
+		if count != 1000 {
+			t.Fatalf("%v: decoded %d items, was expecting 1000", c, count)
+		}
+		err = f.Close()
+		if err != nil {
+			t.Fatalf("%v: error closing file: %v", c, err)
+		}
+	}
+	err := os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+}
+
+func TestChunkCorruption(t *testing.T) {
+	tf, err := Create("test.tea", WithDataType(reflect.TypeOf(Tick{})), WithChunkCompression(Zstd, 4))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		err = tf.Write(Tick{Time: int64(i), Price: 100})
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	data, err := os.ReadFile("test.tea")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+	var section bytes.Buffer
+	err = writeSection(&section, nativeEndian, tf.chunkSection)
+	if err != nil {
+		t.Fatalf("error encoding chunk section: %v", err)
+	}
+	offset := bytes.Index(data, section.Bytes()) + 8
+	if offset < 8 {
+		t.Fatalf("chunk section not found")
+	}
+
+	// Counts from the file are checked before allocating
+	for _, counts := range [][2]int64{{10, 1 << 60}, {-4, -1}, {4 << 40, 1 << 40}} {
+		corrupt := append([]byte{}, data...)
+		nativeEndian.PutUint64(corrupt[offset + 8:], uint64(counts[0]))
+		nativeEndian.PutUint64(corrupt[offset + 16:], uint64(counts[1]))
+		_, err = OpenReadBytes(corrupt, reflect.TypeOf(Tick{}))
+		if !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("%v: was expecting ErrCorrupt, got %v", counts, err)
+		}
+	}
+
+	corrupt := append([]byte{}, data...)
+	nativeEndian.PutUint32(corrupt[tf.header.ItemStart:], 0x7fffffff)
+	r, err := OpenReadBytes(corrupt, reflect.TypeOf(Tick{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	_, err = r.Read()
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("was expecting ErrCorrupt reading a chunk, got %v", err)
+	}
+}
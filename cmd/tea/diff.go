@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/melaurent/goteafiles"
+)
+
+// difference is the comparison of two files printed by diff. Items are
+// compared on the fields present in both files, matched by name.
+type difference struct {
+	A           string      `json:"a"`
+	B           string      `json:"b"`
+	By          string      `json:"by"`
+	Sections    []string    `json:"sections,omitempty"`
+	ItemsA      int64       `json:"itemsA"`
+	ItemsB      int64       `json:"itemsB"`
+	Same        int64       `json:"same"`
+	Changed     int64       `json:"changed"`
+	Removed     int64       `json:"removed"`
+	Inserted    int64       `json:"inserted"`
+	Fields      []fieldDiff `json:"fields,omitempty"`
+	Differences []itemDiff  `json:"differences,omitempty"`
+}
+
+// fieldDiff sums up the changes of a field over the changed items, the
+// deltas being b minus a
+type fieldDiff struct {
+	Name        string  `json:"name"`
+	Changed     int64   `json:"changed"`
+	MaxAbsDelta float64 `json:"maxAbsDelta"`
+	MeanDelta   float64 `json:"meanDelta"`
+	numeric     int64
+}
+
+// itemDiff is an item removed from a, inserted in b or changed between
+// them. The index in a file missing the item is -1.
+type itemDiff struct {
+	Kind   string      `json:"kind"`
+	IndexA int64       `json:"indexA"`
+	IndexB int64       `json:"indexB"`
+	Time   *time.Time  `json:"time,omitempty"`
+	Values []valueDiff `json:"values,omitempty"`
+}
+
+type valueDiff struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+func (d *difference) differs() bool {
+	return len(d.Sections) > 0 || d.Changed > 0 || d.Removed > 0 || d.Inserted > 0
+}
+
+// runDiff exits like diff(1), with status 0 when the files are the same,
+// 1 when they differ and 2 when they cannot be compared
+func runDiff(args []string, stdout io.Writer) error {
+	fs := newFlagSet("diff")
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	by := fs.String("by", "index", "align items by index or time")
+	max := fs.Int("max", 10, "number of item differences to list")
+	err := parseFlags(fs, args)
+	if err != nil { return err }
+	if fs.NArg() != 2 {
+		return &usageError{msg: "diff takes exactly two files"}
+	}
+	if *by != "index" && *by != "time" {
+		return &usageError{msg: fmt.Sprintf("unknown alignment %q, use index or time", *by)}
+	}
+
+	d, err := diff(fs.Arg(0), fs.Arg(1), *by == "time", *max)
+	if err != nil { return &statusError{status: 2, err: err} }
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(d)
+	} else {
+		err = d.print(stdout)
+	}
+	if err != nil { return &statusError{status: 2, err: err} }
+	if d.differs() {
+		return &statusError{status: 1}
+	}
+	return nil
+}
+
+func diff(nameA string, nameB string, byTime bool, max int) (*difference, error) {
+	d := &difference{A: nameA, B: nameB, By: "index"}
+	if byTime {
+		d.By = "time"
+	}
+	inA, err := inspect(nameA)
+	if err != nil { return nil, fmt.Errorf("%s: %v", nameA, err) }
+	inB, err := inspect(nameB)
+	if err != nil { return nil, fmt.Errorf("%s: %v", nameB, err) }
+	d.Sections = diffSections(inA, inB)
+
+	a, err := openFile(nameA)
+	if err != nil { return nil, fmt.Errorf("%s: %v", nameA, err) }
+	defer a.Close()
+	b, err := openFile(nameB)
+	if err != nil { return nil, fmt.Errorf("%s: %v", nameB, err) }
+	defer b.Close()
+	if byTime && (inA.FirstTime == nil && inA.ItemCount > 0 || inB.FirstTime == nil && inB.ItemCount > 0) {
+		return nil, fmt.Errorf("aligning by time needs a time field in both files")
+	}
+	c := newItemComparer(a, b, d, max)
+	if byTime {
+		err = c.byTime()
+	} else {
+		err = c.byIndex()
+	}
+	if err != nil { return nil, err }
+	for i := range d.Fields {
+		if d.Fields[i].numeric > 0 {
+			d.Fields[i].MeanDelta /= float64(d.Fields[i].numeric)
+		}
+	}
+	return d, nil
+}
+
+// diffSections describes the differences between the sections of two
+// files, other than their item counts
+func diffSections(a *inspection, b *inspection) []string {
+	var diffs []string
+	if a.Item.Schema != b.Item.Schema || a.Item.Size != b.Item.Size || len(a.Item.Fields) != len(b.Item.Fields) {
+		diffs = append(diffs, fmt.Sprintf("schema: %s, %s", describeItem(a.Item), describeItem(b.Item)))
+	} else {
+		for i := range a.Item.Fields {
+			if a.Item.Fields[i] != b.Item.Fields[i] {
+				diffs = append(diffs, fmt.Sprintf("schema: %s, %s", describeItem(a.Item), describeItem(b.Item)))
+				break
+			}
+		}
+	}
+	switch {
+	case a.Time == nil && b.Time != nil:
+		diffs = append(diffs, "time section: only in b")
+	case a.Time != nil && b.Time == nil:
+		diffs = append(diffs, "time section: only in a")
+	case a.Time != nil:
+		if a.Time.Epoch != b.Time.Epoch {
+			diffs = append(diffs, fmt.Sprintf("epoch: %d, %d", a.Time.Epoch, b.Time.Epoch))
+		}
+		if a.Time.TicksPerDay != b.Time.TicksPerDay {
+			diffs = append(diffs, fmt.Sprintf("resolution: %s, %s", a.Time.Resolution, b.Time.Resolution))
+		}
+		if strings.Join(a.Time.Fields, ",") != strings.Join(b.Time.Fields, ",") {
+			diffs = append(diffs, fmt.Sprintf("time fields: %s, %s", strings.Join(a.Time.Fields, ","), strings.Join(b.Time.Fields, ",")))
+		}
+	}
+	valuesB := make(map[string]interface{})
+	for _, nv := range b.NameValues {
+		valuesB[nv.Name] = nv.Value
+	}
+	for _, nv := range a.NameValues {
+		value, ok := valuesB[nv.Name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("name value %s: only in a", nv.Name))
+		} else if fmt.Sprint(value) != fmt.Sprint(nv.Value) {
+			diffs = append(diffs, fmt.Sprintf("name value %s: %v, %v", nv.Name, nv.Value, value))
+		}
+		delete(valuesB, nv.Name)
+	}
+	for _, nv := range b.NameValues {
+		if _, ok := valuesB[nv.Name]; ok {
+			diffs = append(diffs, fmt.Sprintf("name value %s: only in b", nv.Name))
+		}
+	}
+	if a.ContentDescription != b.ContentDescription {
+		diffs = append(diffs, fmt.Sprintf("description: %q, %q", a.ContentDescription, b.ContentDescription))
+	}
+	return diffs
+}
+
+func describeItem(item *itemInfo) string {
+	if item.Schema != "" {
+		return item.Schema
+	}
+	var fields []string
+	for _, f := range item.Fields {
+		fields = append(fields, fmt.Sprintf("%s:%s@%d", f.Name, f.Type, f.Offset))
+	}
+	return fmt.Sprintf("%s(%s) of %d bytes", item.TypeName, strings.Join(fields, ", "), item.Size)
+}
+
+// itemComparer reads the items of both files and compares the fields
+// they have in common
+type itemComparer struct {
+	a       *goteafiles.TeaFile
+	b       *goteafiles.TeaFile
+	d       *difference
+	max     int
+	fieldsA []goteafiles.ItemSectionField
+	fieldsB []goteafiles.ItemSectionField
+	itemA   []byte
+	itemB   []byte
+}
+
+func newItemComparer(a *goteafiles.TeaFile, b *goteafiles.TeaFile, d *difference, max int) *itemComparer {
+	c := &itemComparer{
+		a: a,
+		b: b,
+		d: d,
+		max: max,
+		itemA: make([]byte, a.ItemSection().Info.ItemSize),
+		itemB: make([]byte, b.ItemSection().Info.ItemSize),
+	}
+	for _, fa := range a.ItemSection().Fields {
+		for _, fb := range b.ItemSection().Fields {
+			if fa.Name == fb.Name {
+				c.fieldsA = append(c.fieldsA, fa)
+				c.fieldsB = append(c.fieldsB, fb)
+				d.Fields = append(d.Fields, fieldDiff{Name: fa.Name})
+			}
+		}
+	}
+	return c
+}
+
+// next reads the next item of tf into item, returning false at the end
+func next(tf *goteafiles.TeaFile, item []byte) (bool, error) {
+	err := tf.ReadBytes(item)
+	if err == io.EOF {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *itemComparer) byIndex() error {
+	for {
+		okA, err := next(c.a, c.itemA)
+		if err != nil { return err }
+		okB, err := next(c.b, c.itemB)
+		if err != nil { return err }
+		switch {
+		case okA && okB:
+			err = c.compare()
+		case okA:
+			err = c.removed()
+		case okB:
+			err = c.inserted()
+		default:
+			return nil
+		}
+		if err != nil { return err }
+	}
+}
+
+// byTime walks both files in time order, pairing items with the same time
+// in the order they appear
+func (c *itemComparer) byTime() error {
+	okA, err := next(c.a, c.itemA)
+	if err != nil { return err }
+	okB, err := next(c.b, c.itemB)
+	if err != nil { return err }
+	for okA || okB {
+		var timeA, timeB time.Time
+		if okA {
+			timeA, err = c.a.ItemTime(c.itemA)
+			if err != nil { return err }
+		}
+		if okB {
+			timeB, err = c.b.ItemTime(c.itemB)
+			if err != nil { return err }
+		}
+		switch {
+		case okA && okB && timeA.Equal(timeB):
+			err = c.compare()
+			if err != nil { return err }
+			okA, err = next(c.a, c.itemA)
+			if err != nil { return err }
+			okB, err = next(c.b, c.itemB)
+		case okA && (!okB || timeA.Before(timeB)):
+			err = c.removed()
+			if err != nil { return err }
+			okA, err = next(c.a, c.itemA)
+		default:
+			err = c.inserted()
+			if err != nil { return err }
+			okB, err = next(c.b, c.itemB)
+		}
+		if err != nil { return err }
+	}
+	return nil
+}
+
+func (c *itemComparer) compare() error {
+	var values []valueDiff
+	for i := range c.fieldsA {
+		fa, fb := c.fieldsA[i], c.fieldsB[i]
+		sa, err := formatValue(c.a, fa, c.itemA)
+		if err != nil { return err }
+		sb, err := formatValue(c.b, fb, c.itemB)
+		if err != nil { return err }
+		if sa == sb {
+			continue
+		}
+		values = append(values, valueDiff{Field: fa.Name, A: sa, B: sb})
+		fd := &c.d.Fields[i]
+		fd.Changed += 1
+		va, err := fa.Value(c.itemA)
+		if err != nil { return err }
+		vb, err := fb.Value(c.itemB)
+		if err != nil { return err }
+		delta := toFloat(vb) - toFloat(va)
+		if math.IsNaN(delta) || math.IsInf(delta, 0) {
+			continue
+		}
+		fd.numeric += 1
+		fd.MeanDelta += delta
+		if math.Abs(delta) > fd.MaxAbsDelta {
+			fd.MaxAbsDelta = math.Abs(delta)
+		}
+	}
+	c.d.ItemsA += 1
+	c.d.ItemsB += 1
+	if values == nil {
+		c.d.Same += 1
+		return nil
+	}
+	c.d.Changed += 1
+	return c.record("changed", c.d.ItemsA - 1, c.d.ItemsB - 1, c.a, c.itemA, values)
+}
+
+func (c *itemComparer) removed() error {
+	c.d.ItemsA += 1
+	c.d.Removed += 1
+	return c.record("removed", c.d.ItemsA - 1, -1, c.a, c.itemA, nil)
+}
+
+func (c *itemComparer) inserted() error {
+	c.d.ItemsB += 1
+	c.d.Inserted += 1
+	return c.record("inserted", -1, c.d.ItemsB - 1, c.b, c.itemB, nil)
+}
+
+// record lists the difference while fewer than max are listed
+func (c *itemComparer) record(kind string, indexA int64, indexB int64, tf *goteafiles.TeaFile, item []byte, values []valueDiff) error {
+	if len(c.d.Differences) >= c.max {
+		return nil
+	}
+	diff := itemDiff{Kind: kind, IndexA: indexA, IndexB: indexB, Values: values}
+	if ts := tf.TimeSection(); ts != nil && len(ts.Offsets) > 0 {
+		t, err := tf.ItemTime(item)
+		if err != nil { return err }
+		diff.Time = &t
+	}
+	c.d.Differences = append(c.d.Differences, diff)
+	return nil
+}
+
+func (d *difference) print(w io.Writer) error {
+	fmt.Fprintf(w, "a %s, %d items\n", d.A, d.ItemsA)
+	fmt.Fprintf(w, "b %s, %d items\n", d.B, d.ItemsB)
+	if !d.differs() {
+		fmt.Fprintln(w, "no differences")
+		return nil
+	}
+	for _, s := range d.Sections {
+		fmt.Fprintln(w, s)
+	}
+	fmt.Fprintf(w, "items by %s: %d same, %d changed, %d removed, %d inserted\n", d.By, d.Same, d.Changed, d.Removed, d.Inserted)
+	if d.Changed > 0 {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "field\tchanged\tmax |delta|\tmean delta\t\n")
+		for _, f := range d.Fields {
+			if f.Changed > 0 {
+				fmt.Fprintf(tw, "%s\t%d\t%g\t%g\t\n", f.Name, f.Changed, f.MaxAbsDelta, f.MeanDelta)
+			}
+		}
+		err := tw.Flush()
+		if err != nil { return err }
+	}
+	if len(d.Differences) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, diff := range d.Differences {
+		at := ""
+		if diff.Time != nil {
+			at = " at " + diff.Time.Format(time.RFC3339Nano)
+		}
+		switch diff.Kind {
+		case "removed":
+			fmt.Fprintf(w, "removed a[%d]%s\n", diff.IndexA, at)
+		case "inserted":
+			fmt.Fprintf(w, "inserted b[%d]%s\n", diff.IndexB, at)
+		default:
+			fmt.Fprintf(w, "changed a[%d] b[%d]%s\n", diff.IndexA, diff.IndexB, at)
+			for _, v := range diff.Values {
+				fmt.Fprintf(w, "  %s: %s -> %s\n", v.Field, v.A, v.B)
+			}
+		}
+	}
+	if n := d.Changed + d.Removed + d.Inserted; n > int64(len(d.Differences)) {
+		fmt.Fprintf(w, "and %d more\n", n - int64(len(d.Differences)))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/melaurent/goteafiles"
+)
+
+func TestDiff(t *testing.T) {
+	a := writeTicks(t, "a.tea", 10)
+	out := runTea(t, 0, "diff", a, a)
+	if !strings.Contains(out, "no differences") {
+		t.Fatalf("got wrong output:\n%s", out)
+	}
+
+	b := filepath.Join(t.TempDir(), "b.tea")
+	runTea(t, 0, "slice", "-o", b, "-from", tickStart.Add(2 * time.Second).Format(time.RFC3339), a)
+	var d difference
+	out = runTea(t, 1, "diff", "-json", "-by", "time", a, b)
+	err := json.Unmarshal([]byte(out), &d)
+	if err != nil {
+		t.Fatalf("error decoding difference: %v", err)
+	}
+	if d.Same != 8 || d.Changed != 0 || d.Removed != 2 || d.Inserted != 0 || len(d.Differences) != 2 {
+		t.Fatalf("got wrong difference: %+v", d)
+	}
+	if diff := d.Differences[1]; diff.Kind != "removed" || diff.IndexA != 1 || !diff.Time.Equal(tickStart.Add(time.Second)) {
+		t.Fatalf("got wrong item difference: %+v", diff)
+	}
+
+	d = difference{}
+	out = runTea(t, 1, "diff", "-json", "-max", "1", a, b)
+	err = json.Unmarshal([]byte(out), &d)
+	if err != nil {
+		t.Fatalf("error decoding difference: %v", err)
+	}
+	if d.Same != 0 || d.Changed != 8 || d.Removed != 2 || len(d.Differences) != 1 || len(d.Differences[0].Values) != 3 {
+		t.Fatalf("got wrong difference: %+v", d)
+	}
+	if volume := d.Fields[2]; volume.Changed != 8 || volume.MaxAbsDelta != 2 || volume.MeanDelta != 2 {
+		t.Fatalf("got wrong volume difference: %+v", volume)
+	}
+
+	c := writeTicks(t, "c.tea", 12, goteafiles.WithNameValues(map[string]interface{}{"venue": "XLON"}))
+	out = runTea(t, 1, "diff", a, c)
+	for _, s := range []string{"name value venue: XNYS, XLON", "10 same, 0 changed, 0 removed, 2 inserted", "inserted b[10] at 2020-01-02T09:00:10Z"} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("missing %q in output:\n%s", s, out)
+		}
+	}
+	runTea(t, 2, "diff", a)
+	runTea(t, 2, "diff", "-by", "key", a, b)
+	// Files that cannot be compared are trouble, not differences
+	runTea(t, 2, "diff", a, filepath.Join(t.TempDir(), "missing.tea"))
+}
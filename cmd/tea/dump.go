@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/melaurent/goteafiles"
+)
+
+func runDump(args []string, stdout io.Writer) error {
+	fs := newFlagSet("dump")
+	format := fs.String("format", "csv", "output format: csv, tsv or jsonl")
+	columns := fs.String("columns", "", "comma separated fields to print, all by default")
+	from := fs.String("from", "", "first time to print, RFC 3339")
+	to := fs.String("to", "", "time to stop at, excluded, RFC 3339")
+	head := fs.Int("head", 0, "print the first n items only")
+	tail := fs.Int("tail", 0, "print the last n items only")
+	err := parseFlags(fs, args)
+	if err != nil { return err }
+	if fs.NArg() != 1 {
+		return &usageError{msg: "dump takes exactly one file"}
+	}
+	if *head < 0 || *tail < 0 || (*head > 0 && *tail > 0) {
+		return &usageError{msg: "head and tail must be positive and cannot be combined"}
+	}
+	r, err := parseTimeRange(*from, *to)
+	if err != nil { return err }
+
+	tf, err := openFile(fs.Arg(0))
+	if err != nil { return err }
+	defer tf.Close()
+	fields, err := selectFields(tf.ItemSection(), *columns)
+	if err != nil { return err }
+
+	var out rowWriter
+	bw := bufio.NewWriter(stdout)
+	switch *format {
+	case "csv":
+		out = newCSVWriter(bw, ',')
+	case "tsv":
+		out = newCSVWriter(bw, '\t')
+	case "jsonl":
+		out = &jsonlWriter{w: bw}
+	default:
+		return &usageError{msg: fmt.Sprintf("unknown format %q", *format)}
+	}
+	err = out.header(fields)
+	if err != nil { return err }
+
+	it, err := newItemIterator(tf, r)
+	if err != nil { return err }
+	if *tail > 0 {
+		err = it.skipToTail(*tail)
+		if err != nil { return err }
+	}
+	var rows [][]byte
+	for n := 0; *head == 0 || n < *head; n++ {
+		item, err := it.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil { return err }
+		if *tail > 0 {
+			// The tail is kept in a ring when the iterator cannot seek
+			if len(rows) < *tail {
+				rows = append(rows, append([]byte{}, item...))
+			} else {
+				copy(rows[n % *tail], item)
+			}
+			continue
+		}
+		err = out.row(tf, fields, item)
+		if err != nil { return err }
+	}
+	if *tail > 0 && len(rows) == *tail {
+		start := it.count % *tail
+		rows = append(rows[start:], rows[:start]...)
+	}
+	for _, item := range rows {
+		err = out.row(tf, fields, item)
+		if err != nil { return err }
+	}
+	err = out.flush()
+	if err != nil { return err }
+	return bw.Flush()
+}
+
+// timeRange selects the items from From included to To excluded. Zero
+// times leave the range open.
+type timeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func parseTimeRange(from string, to string) (timeRange, error) {
+	var r timeRange
+	var err error
+	if from != "" {
+		r.From, err = parseTime(from)
+		if err != nil { return r, &usageError{msg: fmt.Sprintf("invalid from time: %v", err)} }
+	}
+	if to != "" {
+		r.To, err = parseTime(to)
+		if err != nil { return r, &usageError{msg: fmt.Sprintf("invalid to time: %v", err)} }
+	}
+	return r, nil
+}
+
+// parseTime parses RFC 3339 times, or dates alone
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func (r timeRange) open() bool {
+	return r.From.IsZero() && r.To.IsZero()
+}
+
+// selectFields returns the fields named in the comma separated list, or
+// every field if the list is empty
+func selectFields(is *goteafiles.ItemSection, columns string) ([]goteafiles.ItemSectionField, error) {
+	if columns == "" {
+		return is.Fields, nil
+	}
+	var fields []goteafiles.ItemSectionField
+	for _, name := range strings.Split(columns, ",") {
+		found := false
+		for _, f := range is.Fields {
+			if f.Name == strings.TrimSpace(name) {
+				fields = append(fields, f)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &usageError{msg: fmt.Sprintf("no field %q", name)}
+		}
+	}
+	return fields, nil
+}
+
+// itemIterator reads the items of a file within a time range, which must
+// be sorted by time. It seeks to the start of the range when the file
+// allows it.
+type itemIterator struct {
+	tf    *goteafiles.TeaFile
+	r     timeRange
+	item  []byte
+	count int
+}
+
+func newItemIterator(tf *goteafiles.TeaFile, r timeRange) (*itemIterator, error) {
+	it := &itemIterator{
+		tf: tf,
+		r: r,
+		item: make([]byte, tf.ItemSection().Info.ItemSize),
+	}
+	if r.open() {
+		return it, nil
+	}
+	if tf.TimeSection() == nil || len(tf.TimeSection().Offsets) == 0 {
+		return nil, goteafiles.ErrNoTimeField
+	}
+	if !r.From.IsZero() {
+		_, err := tf.SeekTime(r.From)
+		if err != nil && !errors.Is(err, goteafiles.ErrCompressed) && !errors.Is(err, goteafiles.ErrNotSeekable) {
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+// next returns the next item, valid until the following call
+func (it *itemIterator) next() ([]byte, error) {
+	for {
+		err := it.tf.ReadBytes(it.item)
+		if err != nil { return nil, err }
+		if !it.r.open() {
+			t, err := it.tf.ItemTime(it.item)
+			if err != nil { return nil, err }
+			if !it.r.From.IsZero() && t.Before(it.r.From) {
+				continue
+			}
+			if !it.r.To.IsZero() && !t.Before(it.r.To) {
+				return nil, io.EOF
+			}
+		}
+		it.count += 1
+		return it.item, nil
+	}
+}
+
+// skipToTail seeks to the last n items of a file read without upper time
+// bound, when the file is seekable. Other files are read to the end.
+func (it *itemIterator) skipToTail(n int) error {
+	if !it.r.To.IsZero() {
+		return nil
+	}
+	count, err := it.tf.ItemCount()
+	if err != nil {
+		return nil
+	}
+	start := int64(count - n)
+	if !it.r.From.IsZero() {
+		first, err := it.tf.SeekTime(it.r.From)
+		if err != nil {
+			return nil
+		}
+		if first > start {
+			start = first
+		}
+	}
+	if start <= 0 {
+		return nil
+	}
+	return it.tf.SeekItem(start)
+}
+
+// formatValue formats a field value, time fields being printed as
+// RFC 3339 times
+func formatValue(tf *goteafiles.TeaFile, f goteafiles.ItemSectionField, item []byte) (string, error) {
+	v, err := f.Value(item)
+	if err != nil { return "", err }
+	if ts := tf.TimeSection(); ts.IsTimeField(f) {
+		switch ticks := v.(type) {
+		case int64:
+			return ts.Time(ticks).Format(time.RFC3339Nano), nil
+		case uint64:
+			return ts.Time(int64(ticks)).Format(time.RFC3339Nano), nil
+		}
+	}
+	switch v := v.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// rowWriter writes items in an output format
+type rowWriter interface {
+	header(fields []goteafiles.ItemSectionField) error
+	row(tf *goteafiles.TeaFile, fields []goteafiles.ItemSectionField, item []byte) error
+	flush() error
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer, comma rune) *csvWriter {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &csvWriter{w: cw}
+}
+
+func (cw *csvWriter) header(fields []goteafiles.ItemSectionField) error {
+	cw.record = cw.record[:0]
+	for _, f := range fields {
+		cw.record = append(cw.record, f.Name)
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) row(tf *goteafiles.TeaFile, fields []goteafiles.ItemSectionField, item []byte) error {
+	cw.record = cw.record[:0]
+	for _, f := range fields {
+		s, err := formatValue(tf, f, item)
+		if err != nil { return err }
+		cw.record = append(cw.record, s)
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// jsonlWriter writes an object per item, keeping the field order.
+// Numbers are written as JSON numbers, except decimals which are written
+// as strings to keep their precision.
+type jsonlWriter struct {
+	w *bufio.Writer
+}
+
+func (jw *jsonlWriter) header(fields []goteafiles.ItemSectionField) error {
+	return nil
+}
+
+func (jw *jsonlWriter) row(tf *goteafiles.TeaFile, fields []goteafiles.ItemSectionField, item []byte) error {
+	jw.w.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			jw.w.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.Name)
+		jw.w.Write(name)
+		jw.w.WriteByte(':')
+		s, err := formatValue(tf, f, item)
+		if err != nil { return err }
+		numeric := f.Type != goteafiles.FIELD_TYPE_NET_DECIMAL && !tf.TimeSection().IsTimeField(f)
+		// NaN and infinities are not JSON numbers
+		if numeric && s != "NaN" && !strings.HasSuffix(s, "Inf") {
+			jw.w.WriteString(s)
+		} else {
+			value, _ := json.Marshal(s)
+			jw.w.Write(value)
+		}
+	}
+	_, err := jw.w.WriteString("}\n")
+	return err
+}
+
+func (jw *jsonlWriter) flush() error {
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	path := writeTicks(t, "ticks.tea", 100)
+	gzPath := writeTicks(t, "ticks.tea.gz", 100)
+
+	for _, p := range []string{path, gzPath} {
+		out := runTea(t, 0, "dump", "-head", "2", p)
+		if out != "Time,Price,Volume\n2020-01-02T09:00:00Z,100,0\n2020-01-02T09:00:01Z,100.25,1\n" {
+			t.Fatalf("%s: got wrong head:\n%s", p, out)
+		}
+		out = runTea(t, 0, "dump", "-format", "tsv", "-columns", "Volume,Time", "-tail", "2", p)
+		if out != "Volume\tTime\n98\t2020-01-02T09:01:38Z\n99\t2020-01-02T09:01:39Z\n" {
+			t.Fatalf("%s: got wrong tail:\n%s", p, out)
+		}
+		out = runTea(t, 0, "dump", "-format", "jsonl", "-from", "2020-01-02T09:00:10Z", "-to", "2020-01-02T09:00:12Z", p)
+		if out != `{"Time":"2020-01-02T09:00:10Z","Price":102.5,"Volume":10}` + "\n" + `{"Time":"2020-01-02T09:00:11Z","Price":102.75,"Volume":11}` + "\n" {
+			t.Fatalf("%s: got wrong range:\n%s", p, out)
+		}
+		out = runTea(t, 0, "dump", "-from", "2020-01-02T09:01:35Z", "-tail", "2", "-columns", "Volume", p)
+		if out != "Volume\n98\n99\n" {
+			t.Fatalf("%s: got wrong tail of range:\n%s", p, out)
+		}
+	}
+
+	runTea(t, 2, "dump", "-columns", "Bid", path)
+	runTea(t, 2, "dump", "-format", "xml", path)
+	runTea(t, 2, "dump", "-head", "1", "-tail", "1", path)
+}
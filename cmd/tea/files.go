@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"github.com/melaurent/goteafiles"
+)
+
+// openFile opens a file for reading without a Go type. Its items are read
+// with ReadBytes.
+func openFile(name string) (*goteafiles.TeaFile, error) {
+	return goteafiles.OpenRead(name, nil)
+}
+
+// firstLast returns the first and last items and the item count of a
+// file, seeking when possible and reading every item otherwise
+func firstLast(tf *goteafiles.TeaFile) ([]byte, []byte, int64, error) {
+	size := int(tf.ItemSection().Info.ItemSize)
+	first := make([]byte, size)
+	last := make([]byte, size)
+	n, err := tf.ItemCount()
+	if err == nil {
+		if n == 0 {
+			return nil, nil, 0, nil
+		}
+		err = tf.ReadBytes(first)
+		if err != nil { return nil, nil, 0, err }
+		err = tf.SeekItem(int64(n - 1))
+		if err != nil { return nil, nil, 0, err }
+		err = tf.ReadBytes(last)
+		if err != nil { return nil, nil, 0, err }
+		return first, last, int64(n), nil
+	}
+	if !errors.Is(err, goteafiles.ErrCompressed) && !errors.Is(err, goteafiles.ErrNotSeekable) {
+		return nil, nil, 0, err
+	}
+
+	var count int64
+	for {
+		err := tf.ReadBytes(last)
+		if err == io.EOF {
+			break
+		}
+		if err != nil { return nil, nil, 0, err }
+		if count == 0 {
+			copy(first, last)
+		}
+		count += 1
+	}
+	if count == 0 {
+		return nil, nil, 0, nil
+	}
+	return first, last, count, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/melaurent/goteafiles"
+)
+
+// nameValueFlags collects repeated -name key=value flags
+type nameValueFlags goteafiles.NameValues
+
+func (nv *nameValueFlags) String() string {
+	return fmt.Sprint(goteafiles.NameValues(*nv))
+}
+
+// Set parses key=value, values being stored as int32 or float64 when
+// they are numbers and as text otherwise
+func (nv *nameValueFlags) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("name value %q is not name=value", s)
+	}
+	name, text := s[:i], s[i+1:]
+	var value interface{} = text
+	if v, err := strconv.ParseInt(text, 10, 32); err == nil {
+		value = int32(v)
+	} else if v, err := strconv.ParseFloat(text, 64); err == nil {
+		value = v
+	}
+	*nv = append(*nv, goteafiles.NameValue{Name: name, Value: value})
+	return nil
+}
+
+// readSchema parses a schema given on the command line, reading it from a
+// file when it starts with @
+func readSchema(text string) (goteafiles.Schema, error) {
+	if strings.HasPrefix(text, "@") {
+		b, err := os.ReadFile(text[1:])
+		if err != nil { return goteafiles.Schema{}, err }
+		text = string(b)
+	}
+	schema, err := goteafiles.ParseSchema(text)
+	if err != nil { return schema, &usageError{msg: err.Error()} }
+	if schema.TypeName == "" {
+		schema.TypeName = "Item"
+	}
+	return schema, nil
+}
+
+// resolutions maps resolution names to ticks per day
+var resolutions = map[string]int64{
+	"day"   : 1,
+	"s"     : 86400,
+	"ms"    : 86400000,
+	"us"    : 86400000000,
+	"100ns" : 864000000000,
+	"ns"    : 86400000000000,
+}
+
+func runImport(args []string, stdout io.Writer) error {
+	fs := newFlagSet("import")
+	output := fs.String("o", "", "file to create")
+	schemaText := fs.String("schema", "", "schema such as Tick(Time:Int64, Price:Double), or @file holding its text or JSON form")
+	format := fs.String("format", "csv", "input format: csv, tsv or jsonl")
+	timeFields := fs.String("time", "", "comma separated time fields")
+	timeFormat := fs.String("time-format", "", "time layout, or unix, unixms, unixus, unixns or ticks; RFC 3339 by default")
+	resolution := fs.String("resolution", "ms", "time resolution: day, s, ms, us, 100ns or ns")
+	epoch := fs.Int64("epoch", 719162, "epoch in days since 0001-01-01")
+	description := fs.String("description", "", "content description")
+	var nameValues nameValueFlags
+	fs.Var(&nameValues, "name", "name value as name=value, may be repeated")
+	err := parseFlags(fs, args)
+	if err != nil { return err }
+	if fs.NArg() != 1 || *output == "" || *schemaText == "" {
+		return &usageError{msg: "import takes an input file, - for stdin, and requires -o and -schema"}
+	}
+
+	schema, err := readSchema(*schemaText)
+	if err != nil { return err }
+	configs := []goteafiles.TeaFileConfig{goteafiles.WithSchema(schema)}
+	if *timeFields != "" {
+		ticksPerDay, ok := resolutions[*resolution]
+		if !ok {
+			return &usageError{msg: fmt.Sprintf("unknown resolution %q", *resolution)}
+		}
+		var indexes []int32
+		for _, name := range strings.Split(*timeFields, ",") {
+			index := -1
+			for i, f := range schema.Fields {
+				if f.Name == name {
+					index = i
+				}
+			}
+			if index < 0 {
+				return &usageError{msg: fmt.Sprintf("no time field %q in schema", name)}
+			}
+			indexes = append(indexes, int32(index))
+		}
+		configs = append(configs, goteafiles.WithTimeFields(*epoch, ticksPerDay, indexes))
+	}
+	if *description != "" {
+		configs = append(configs, goteafiles.WithContentDescription(*description))
+	}
+	if len(nameValues) > 0 {
+		configs = append(configs, goteafiles.WithOrderedNameValues(goteafiles.NameValues(nameValues)))
+	}
+	opts := goteafiles.ImportOptions{TimeFormat: *timeFormat}
+	switch *format {
+	case "csv":
+	case "tsv":
+		opts.Comma = '\t'
+	case "jsonl":
+		opts.Format = goteafiles.JSONLines
+	default:
+		return &usageError{msg: fmt.Sprintf("unknown format %q", *format)}
+	}
+
+	var r io.Reader = os.Stdin
+	if fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil { return err }
+		defer f.Close()
+		r = f
+	}
+	tf, err := goteafiles.Create(*output, configs...)
+	if err != nil { return err }
+	n, err := goteafiles.Import(tf, r, opts)
+	if err != nil {
+		_ = tf.Close()
+		_ = os.Remove(*output)
+		return err
+	}
+	err = tf.Close()
+	if err != nil { return err }
+	fmt.Fprintf(stdout, "imported %d items into %s\n", n, *output)
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "ticks.jsonl")
+	err := os.WriteFile(input, []byte(
+		`{"Time": "2020-01-02T09:00:00Z", "Price": 100, "Volume": 0}` + "\n" +
+		`{"Time": "2020-01-02T09:00:01Z", "Price": 100.25, "Volume": 1}` + "\n"), 0666)
+	if err != nil {
+		t.Fatalf("error writing input: %v", err)
+	}
+	output := filepath.Join(dir, "ticks.tea")
+	runTea(t, 0, "import", "-o", output, "-format", "jsonl",
+		"-schema", "Tick(Time:Int64, Price:Double, Volume:Int32)", "-time", "Time",
+		"-name", "venue=XNYS", "-description", "ticks", input)
+
+	// The imported file holds the same items as one written from Go
+	want := runTea(t, 0, "dump", writeTicks(t, "want.tea", 2))
+	if got := runTea(t, 0, "dump", output); got != want {
+		t.Fatalf("got items:\n%s\nwas expecting:\n%s", got, want)
+	}
+	if got := runTea(t, 0, "inspect", "-json", output); !strings.Contains(got, `"value": "XNYS"`) {
+		t.Fatalf("got wrong name values:\n%s", got)
+	}
+
+	err = os.WriteFile(input, []byte(`{"Time": "2020-01-02T09:00:00Z", "Price": 100, "Volume": 1e10}` + "\n"), 0666)
+	if err != nil {
+		t.Fatalf("error writing input: %v", err)
+	}
+	schema := filepath.Join(dir, "schema.json")
+	err = os.WriteFile(schema, []byte(`{"fields": [{"name": "Time", "type": "Int64"}, {"name": "Price", "type": "Double"}, {"name": "Volume", "type": "Int32"}]}`), 0666)
+	if err != nil {
+		t.Fatalf("error writing schema: %v", err)
+	}
+	runTea(t, 1, "import", "-o", output, "-format", "jsonl", "-schema", "@" + schema, input)
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatalf("failed import left %s behind", output)
+	}
+	runTea(t, 2, "import", "-o", output, "-schema", "Time:Int128", input)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/melaurent/goteafiles"
+)
+
+// inspection is the description of a file printed by inspect
+type inspection struct {
+	File               string          `json:"file"`
+	Header             headerInfo      `json:"header"`
+	Sections           []sectionInfo   `json:"sections"`
+	Item               *itemInfo       `json:"item"`
+	Time               *timeInfo       `json:"time,omitempty"`
+	NameValues         []nameValueInfo `json:"nameValues,omitempty"`
+	ContentDescription string          `json:"contentDescription,omitempty"`
+	ItemCount          int64           `json:"itemCount"`
+	FirstTime          *time.Time      `json:"firstTime,omitempty"`
+	LastTime           *time.Time      `json:"lastTime,omitempty"`
+}
+
+type headerInfo struct {
+	ItemStart    int64 `json:"itemStart"`
+	ItemEnd      int64 `json:"itemEnd"`
+	SectionCount int64 `json:"sectionCount"`
+}
+
+type sectionInfo struct {
+	ID   int32  `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type itemInfo struct {
+	TypeName string      `json:"typeName"`
+	Size     int32       `json:"size"`
+	Fields   []fieldInfo `json:"fields"`
+	Schema   string      `json:"schema,omitempty"`
+}
+
+type fieldInfo struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Offset int32  `json:"offset"`
+	Time   bool   `json:"time,omitempty"`
+}
+
+type timeInfo struct {
+	Epoch       int64     `json:"epoch"`
+	EpochDate   time.Time `json:"epochDate"`
+	TicksPerDay int64     `json:"ticksPerDay"`
+	Resolution  string    `json:"resolution"`
+	Fields      []string  `json:"fields"`
+}
+
+type nameValueInfo struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+var sectionNames = map[int32]string{
+	goteafiles.ITEM_SECTION_ID               : "item",
+	goteafiles.CONTENT_DESCRIPTION_SECTION_ID: "content description",
+	goteafiles.NAME_VALUE_SECTION_ID         : "name values",
+	goteafiles.TIME_SECTION_ID               : "time",
+	goteafiles.CHUNK_SECTION_ID              : "chunks",
+	goteafiles.ENCODING_SECTION_ID           : "encoding",
+	goteafiles.CHECKSUM_SECTION_ID           : "checksums",
+}
+
+func runInspect(args []string, stdout io.Writer) error {
+	fs := newFlagSet("inspect")
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	err := parseFlags(fs, args)
+	if err != nil { return err }
+	if fs.NArg() == 0 {
+		return &usageError{msg: "no file given"}
+	}
+
+	for _, name := range fs.Args() {
+		in, err := inspect(name)
+		if err != nil { return fmt.Errorf("%s: %v", name, err) }
+		if *asJSON {
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(in)
+		} else {
+			err = in.print(stdout)
+		}
+		if err != nil { return err }
+	}
+	return nil
+}
+
+func inspect(name string) (*inspection, error) {
+	tf, err := openFile(name)
+	if err != nil { return nil, err }
+	defer tf.Close()
+
+	in := &inspection{
+		File: name,
+		Header: headerInfo{
+			ItemStart: tf.Header().ItemStart,
+			ItemEnd: tf.Header().ItemEnd,
+			SectionCount: tf.Header().SectionCount,
+		},
+		ContentDescription: tf.ContentDescription(),
+	}
+	for _, s := range tf.Sections() {
+		in.Sections = append(in.Sections, sectionInfo{
+			ID: s.ID(),
+			Name: sectionNames[s.ID()],
+			Size: s.Size(),
+		})
+	}
+	is := tf.ItemSection()
+	ts := tf.TimeSection()
+	in.Item = &itemInfo{TypeName: is.Info.ItemTypeName, Size: is.Info.ItemSize}
+	if schema, err := goteafiles.SchemaOf(is); err == nil {
+		in.Item.Schema = schema.String()
+	}
+	for _, f := range is.Fields {
+		in.Item.Fields = append(in.Item.Fields, fieldInfo{
+			Name: f.Name,
+			Type: goteafiles.FieldTypeName(f.Type),
+			Offset: f.Offset,
+			Time: ts.IsTimeField(f),
+		})
+	}
+	if ts != nil {
+		in.Time = &timeInfo{
+			Epoch: ts.Epoch,
+			EpochDate: ts.Time(0),
+			TicksPerDay: ts.TicksPerDay,
+			Resolution: resolution(ts.TicksPerDay),
+		}
+		for _, f := range is.Fields {
+			if ts.IsTimeField(f) {
+				in.Time.Fields = append(in.Time.Fields, f.Name)
+			}
+		}
+	}
+	for _, nv := range tf.GetOrderedNameValues() {
+		value := nv.Value
+		if s, ok := value.(fmt.Stringer); ok {
+			value = s.String()
+		}
+		in.NameValues = append(in.NameValues, nameValueInfo{Name: nv.Name, Value: value})
+	}
+
+	first, last, count, err := firstLast(tf)
+	if err != nil { return nil, err }
+	in.ItemCount = count
+	if count > 0 && in.Time != nil && len(in.Time.Fields) > 0 {
+		firstTime, err := tf.ItemTime(first)
+		if err != nil { return nil, err }
+		lastTime, err := tf.ItemTime(last)
+		if err != nil { return nil, err }
+		in.FirstTime = &firstTime
+		in.LastTime = &lastTime
+	}
+	return in, nil
+}
+
+// resolution names the time resolution of the given ticks per day
+func resolution(ticksPerDay int64) string {
+	switch ticksPerDay {
+	case 1:
+		return "day"
+	case 86400:
+		return "second"
+	case 86400000:
+		return "millisecond"
+	case 86400000000:
+		return "microsecond"
+	case 864000000000:
+		return "100 nanoseconds"
+	case 86400000000000:
+		return "nanosecond"
+	}
+	return fmt.Sprintf("1/%d day", ticksPerDay)
+}
+
+func (in *inspection) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "file\t%s\n", in.File)
+	fmt.Fprintf(tw, "item start\t%d\n", in.Header.ItemStart)
+	fmt.Fprintf(tw, "item end\t%d\n", in.Header.ItemEnd)
+	fmt.Fprintf(tw, "section count\t%d\n", in.Header.SectionCount)
+	for _, s := range in.Sections {
+		fmt.Fprintf(tw, "section %#x\t%s, %d bytes\n", s.ID, s.Name, s.Size)
+	}
+	fmt.Fprintf(tw, "item type\t%s, %d bytes\n", in.Item.TypeName, in.Item.Size)
+	for _, f := range in.Item.Fields {
+		flag := ""
+		if f.Time {
+			flag = ", time"
+		}
+		fmt.Fprintf(tw, "  %s\t%s at offset %d%s\n", f.Name, f.Type, f.Offset, flag)
+	}
+	if in.Item.Schema != "" {
+		fmt.Fprintf(tw, "schema\t%s\n", in.Item.Schema)
+	}
+	if in.Time != nil {
+		fmt.Fprintf(tw, "epoch\t%d (%s)\n", in.Time.Epoch, in.Time.EpochDate.Format("2006-01-02"))
+		fmt.Fprintf(tw, "resolution\t%s (%d ticks per day)\n", in.Time.Resolution, in.Time.TicksPerDay)
+	}
+	if len(in.NameValues) > 0 {
+		fmt.Fprintf(tw, "name values\t\n")
+		for _, nv := range in.NameValues {
+			fmt.Fprintf(tw, "  %s\t%v\n", nv.Name, nv.Value)
+		}
+	}
+	if in.ContentDescription != "" {
+		fmt.Fprintf(tw, "description\t%s\n", in.ContentDescription)
+	}
+	fmt.Fprintf(tw, "item count\t%d\n", in.ItemCount)
+	if in.FirstTime != nil {
+		fmt.Fprintf(tw, "first time\t%s\n", in.FirstTime.Format(time.RFC3339Nano))
+		fmt.Fprintf(tw, "last time\t%s\n", in.LastTime.Format(time.RFC3339Nano))
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+const acme = "../../test-fixtures/acme.tea"
+
+func TestInspect(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"inspect", "-json", acme}, &stdout, &stderr); status != 0 {
+		t.Fatalf("inspect exited with status %d: %s", status, stderr.String())
+	}
+	var in inspection
+	err := json.Unmarshal(stdout.Bytes(), &in)
+	if err != nil {
+		t.Fatalf("error decoding inspection: %v", err)
+	}
+	if in.Header.ItemStart != 264 || in.Header.SectionCount != 4 {
+		t.Fatalf("got wrong header: %+v", in.Header)
+	}
+	if len(in.Item.Fields) != 5 || in.Item.Fields[0].Name != "Time" || !in.Item.Fields[0].Time {
+		t.Fatalf("got wrong item section: %+v", in.Item)
+	}
+	if in.Time == nil || in.Time.Resolution != "millisecond" {
+		t.Fatalf("got wrong time section: %+v", in.Time)
+	}
+	if in.ItemCount != 2 || !in.FirstTime.Equal(time.Date(2011, 3, 4, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got wrong items: %d from %v", in.ItemCount, in.FirstTime)
+	}
+	if in.ContentDescription != "prices of acme at NYSE" || len(in.NameValues) != 2 {
+		t.Fatalf("got wrong description or name values: %+v", in)
+	}
+
+	stdout.Reset()
+	if status := run([]string{"inspect", acme}, &stdout, &stderr); status != 0 {
+		t.Fatalf("inspect exited with status %d: %s", status, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "first time     2011-03-04T09:00:00Z") {
+		t.Fatalf("got wrong text output:\n%s", stdout.String())
+	}
+
+	if status := run([]string{"inspect"}, &stdout, &stderr); status != 2 {
+		t.Fatalf("was expecting status 2 without file, got %d", status)
+	}
+	if status := run([]string{"inspect", "missing.tea"}, &stdout, &stderr); status != 1 {
+		t.Fatalf("was expecting status 1 for a missing file, got %d", status)
+	}
+}
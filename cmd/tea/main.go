@@ -0,0 +1,156 @@
+// Command tea inspects and transforms TeaFiles.
+//
+// Usage:
+//
+//	tea <command> [flags] [files]
+//
+// Run tea help <command> for the flags of a command.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// command is a subcommand of tea. Its run function parses its flags from
+// args and writes its output to stdout.
+type command struct {
+	usage string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"inspect":  {"inspect [-json] file...\tprint the header and sections of files", runInspect},
+	"import":   {"import -o file -schema s [-time fields] [flags] input\tcreate a file from CSV, TSV or JSON Lines", runImport},
+	"merge":    {"merge -o file [-dedup] file...\tmerge files sorted by time", runMerge},
+	"split":    {"split [-o prefix] -period day|hour | -items n file\tsplit a file by time or item count", runSplit},
+	"slice":    {"slice -o file [-from t] [-to t] file\textract a time range into a new file", runSlice},
+	"validate": {"validate [-json] file...\tcheck files, exiting with status 1 if any is invalid", runValidate},
+	"stats":    {"stats [-json] [-gap d] file\tprint field statistics, time coverage and gaps", runStats},
+	"diff":     {"diff [-json] [-by index|time] [-max n] a b\tcompare sections and items, exiting with status 0 if they are the same, 1 if they differ and 2 on trouble", runDiff},
+	"dump":     {"dump [-format f] [-columns a,b] [-from t] [-to t] [-head n|-tail n] file\tprint items as CSV, TSV or JSON Lines", runDump},
+}
+
+// usageError is returned for invalid command lines, and exits with
+// status 2. Requested help exits with status 0.
+type usageError struct {
+	msg  string
+	help bool
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// statusError exits with the given status, printing err unless it is nil
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.status)
+	}
+	return e.err.Error()
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: tea <command> [flags] [files]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\n", commands[name].usage)
+	}
+	tw.Flush()
+}
+
+// newFlagSet returns a flag set for the named command that returns errors
+// instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseFlags parses the flags of a command, turning flag errors into
+// usage errors
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		return &usageError{msg: fmt.Sprintf("usage of %s:\n%s", fs.Name(), flagDefaults(fs)), help: true}
+	}
+	if err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	return nil
+}
+
+func flagDefaults(fs *flag.FlagSet) string {
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+	return buf.String()
+}
+
+// run executes the command line and returns the exit status
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	name := args[0]
+	if name == "help" {
+		if len(args) > 1 {
+			if _, ok := commands[args[1]]; ok {
+				return run([]string{args[1], "-h"}, stdout, stderr)
+			}
+		}
+		usage(stdout)
+		return 0
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "tea: unknown command %q\n", name)
+		usage(stderr)
+		return 2
+	}
+	err := cmd.run(args[1:], stdout)
+	if err == nil {
+		return 0
+	}
+	var serr *statusError
+	if errors.As(err, &serr) {
+		if serr.err != nil {
+			fmt.Fprintf(stderr, "tea %s: %v\n", name, serr.err)
+		}
+		return serr.status
+	}
+	var uerr *usageError
+	if errors.As(err, &uerr) && uerr.help {
+		fmt.Fprintf(stdout, "%s\n", uerr.msg)
+		return 0
+	}
+	fmt.Fprintf(stderr, "tea %s: %v\n", name, err)
+	if uerr != nil {
+		return 2
+	}
+	return 1
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/melaurent/goteafiles"
+)
+
+type Tick struct {
+	Time   int64
+	Price  float64
+	Volume int32
+}
+
+var tickStart = time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+
+var tickTime = goteafiles.TimeSection{Epoch: 719162, TicksPerDay: 86400000}
+
+// writeTicks creates a file of n ticks, one per second from tickStart, in
+// a temporary directory
+func writeTicks(t *testing.T, name string, n int, configs ...goteafiles.TeaFileConfig) string {
+	path := filepath.Join(t.TempDir(), name)
+	configs = append([]goteafiles.TeaFileConfig{
+		goteafiles.WithDataType(reflect.TypeOf(Tick{})),
+		goteafiles.WithTimeFields(tickTime.Epoch, tickTime.TicksPerDay, []int32{0}),
+		goteafiles.WithNameValues(map[string]interface{}{"venue": "XNYS"}),
+		goteafiles.WithContentDescription("ticks"),
+	}, configs...)
+	tf, err := goteafiles.Create(path, configs...)
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		err = tf.Write(Tick{
+			Time: tickTime.Ticks(tickStart.Add(time.Duration(i) * time.Second)),
+			Price: 100 + float64(i) / 4,
+			Volume: int32(i),
+		})
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	return path
+}
+
+// runTea runs a command line, failing the test if its status is not the
+// expected one, and returns its output
+func runTea(t *testing.T, status int, args ...string) string {
+	var stdout, stderr bytes.Buffer
+	if s := run(args, &stdout, &stderr); s != status {
+		t.Fatalf("%v exited with status %d, was expecting %d: %s", args, s, status, stderr.String())
+	}
+	return stdout.String()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/melaurent/goteafiles"
+)
+
+func runMerge(args []string, stdout io.Writer) error {
+	fs := newFlagSet("merge")
+	output := fs.String("o", "", "file to create")
+	dedup := fs.Bool("dedup", false, "drop items identical to an item with the same time")
+	err := parseFlags(fs, args)
+	if err != nil { return err }
+	if fs.NArg() == 0 || *output == "" {
+		return &usageError{msg: "merge takes input files and requires -o"}
+	}
+
+	var inputs []*goteafiles.TeaFile
+	defer func() {
+		for _, tf := range inputs {
+			_ = tf.Close()
+		}
+	}()
+	for _, name := range fs.Args() {
+		tf, err := openFile(name)
+		if err != nil { return fmt.Errorf("%s: %v", name, err) }
+		inputs = append(inputs, tf)
+	}
+
+	// The output takes the sections of the first input
+	out, err := goteafiles.Create(*output, goteafiles.WithSectionsOf(inputs[0]))
+	if err != nil { return err }
+	n, err := goteafiles.MergeSorted(out, goteafiles.MergeOptions{Dedup: *dedup}, inputs...)
+	if err != nil {
+		_ = out.Close()
+		_ = os.Remove(*output)
+		return err
+	}
+	err = out.Close()
+	if err != nil { return err }
+	fmt.Fprintf(stdout, "merged %d items into %s\n", n, *output)
+	return nil
+}
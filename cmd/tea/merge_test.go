@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	a := writeTicks(t, "a.tea", 10)
+	b := writeTicks(t, "b.tea", 5)
+	output := filepath.Join(t.TempDir(), "merged.tea")
+
+	runTea(t, 0, "merge", "-o", output, a, b)
+	out := runTea(t, 0, "dump", "-columns", "Volume", "-head", "6", output)
+	if out != "Volume\n0\n0\n1\n1\n2\n2\n" {
+		t.Fatalf("got wrong merge:\n%s", out)
+	}
+	if out := runTea(t, 0, "inspect", output); !strings.Contains(out, "item count     15") || !strings.Contains(out, "venue") {
+		t.Fatalf("got wrong merged file:\n%s", out)
+	}
+
+	runTea(t, 0, "merge", "-o", output, "-dedup", a, b)
+	if out := runTea(t, 0, "inspect", output); !strings.Contains(out, "item count     10") {
+		t.Fatalf("got wrong deduplicated file:\n%s", out)
+	}
+
+	runTea(t, 2, "merge", a, b)
+	runTea(t, 1, "merge", "-o", output, a, "../../test-fixtures/acme.tea")
+}
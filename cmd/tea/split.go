@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/melaurent/goteafiles"
+)
+
+// splitWriter writes items to the file of their key, creating a file
+// each time the key changes. Outputs take the sections of the input.
+type splitWriter struct {
+	src   *goteafiles.TeaFile
+	out   *goteafiles.TeaFile
+	key   string
+	seen  map[string]bool
+	files []string
+}
+
+func (sw *splitWriter) write(key string, name string, item []byte) error {
+	if sw.out == nil || key != sw.key {
+		err := sw.close()
+		if err != nil { return err }
+		if sw.seen[key] {
+			return fmt.Errorf("items of %s are not contiguous, the file is not sorted by time", key)
+		}
+		sw.seen[key] = true
+		sw.key = key
+		sw.out, err = goteafiles.Create(name, goteafiles.WithSectionsOf(sw.src))
+		if err != nil { return err }
+		sw.files = append(sw.files, name)
+	}
+	return sw.out.WriteBytes(item)
+}
+
+func (sw *splitWriter) close() error {
+	if sw.out == nil {
+		return nil
+	}
+	err := sw.out.Close()
+	sw.out = nil
+	return err
+}
+
+// outputPrefix returns the prefix of split files: the given one, or the
+// input file name without its extensions
+func outputPrefix(prefix string, input string) string {
+	if prefix != "" {
+		return prefix
+	}
+	dir, base := filepath.Split(input)
+	if i := strings.Index(base, "."); i > 0 {
+		base = base[:i]
+	}
+	return filepath.Join(dir, base)
+}
+
+func runSplit(args []string, stdout io.Writer) error {
+	fs := newFlagSet("split")
+	prefix := fs.String("o", "", "prefix of the files to create, the input name without extension by default")
+	period := fs.String("period", "", "split by time: day or hour")
+	items := fs.Int("items", 0, "split in files of n items")
+	err := parseFlags(fs, args)
+	if err != nil { return err }
+	if fs.NArg() != 1 {
+		return &usageError{msg: "split takes exactly one file"}
+	}
+	if (*period == "") == (*items == 0) || *items < 0 {
+		return &usageError{msg: "split requires either -period or a positive -items"}
+	}
+	var layout string
+	switch *period {
+	case "":
+	case "day":
+		layout = "2006-01-02"
+	case "hour":
+		layout = "2006-01-02T15"
+	default:
+		return &usageError{msg: fmt.Sprintf("unknown period %q", *period)}
+	}
+
+	tf, err := openFile(fs.Arg(0))
+	if err != nil { return err }
+	defer tf.Close()
+	sw := &splitWriter{src: tf, seen: make(map[string]bool)}
+	err = sw.split(outputPrefix(*prefix, fs.Arg(0)), *items, layout)
+	cerr := sw.close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil { return err }
+	for _, name := range sw.files {
+		fmt.Fprintln(stdout, name)
+	}
+	return nil
+}
+
+// split writes the items of the input to files named after the prefix
+// and the index of the items divided by n, or the time formatted with
+// layout when n is zero
+func (sw *splitWriter) split(prefix string, n int, layout string) error {
+	item := make([]byte, sw.src.ItemSection().Info.ItemSize)
+	for i := 0; ; i++ {
+		err := sw.src.ReadBytes(item)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil { return err }
+		var key string
+		if n > 0 {
+			key = fmt.Sprintf("%05d", i / n)
+		} else {
+			t, err := sw.src.ItemTime(item)
+			if err != nil { return err }
+			key = t.Format(layout)
+		}
+		err = sw.write(key, prefix + "-" + key + ".tea", item)
+		if err != nil { return err }
+	}
+}
+
+func runSlice(args []string, stdout io.Writer) error {
+	fs := newFlagSet("slice")
+	output := fs.String("o", "", "file to create")
+	from := fs.String("from", "", "first time to extract, RFC 3339")
+	to := fs.String("to", "", "time to stop at, excluded, RFC 3339")
+	err := parseFlags(fs, args)
+	if err != nil { return err }
+	if fs.NArg() != 1 || *output == "" {
+		return &usageError{msg: "slice takes exactly one file and requires -o"}
+	}
+	r, err := parseTimeRange(*from, *to)
+	if err != nil { return err }
+
+	tf, err := openFile(fs.Arg(0))
+	if err != nil { return err }
+	defer tf.Close()
+	it, err := newItemIterator(tf, r)
+	if err != nil { return err }
+	out, err := goteafiles.Create(*output, goteafiles.WithSectionsOf(tf))
+	if err != nil { return err }
+	var n int64
+	for {
+		item, err := it.next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = out.WriteBytes(item)
+		}
+		if err != nil {
+			_ = out.Close()
+			_ = os.Remove(*output)
+			return err
+		}
+		n += 1
+	}
+	err = out.Close()
+	if err != nil { return err }
+	fmt.Fprintf(stdout, "extracted %d items into %s\n", n, *output)
+	return nil
+}
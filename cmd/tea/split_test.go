@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	// Ticks from 09:00:00 to 11:01:39
+	input := writeTicks(t, "ticks.tea", 7300)
+	dir := t.TempDir()
+
+	out := runTea(t, 0, "split", "-period", "hour", "-o", filepath.Join(dir, "ticks"), input)
+	files := strings.Fields(out)
+	if len(files) != 3 || files[0] != filepath.Join(dir, "ticks-2020-01-02T09.tea") {
+		t.Fatalf("got wrong files %v", files)
+	}
+	if inspect := runTea(t, 0, "inspect", files[2]); !strings.Contains(inspect, "item count     100") {
+		t.Fatalf("got wrong last hour:\n%s", inspect)
+	}
+
+	out = runTea(t, 0, "split", "-items", "3000", "-o", filepath.Join(dir, "part"), input)
+	files = strings.Fields(out)
+	if len(files) != 3 || files[2] != filepath.Join(dir, "part-00002.tea") {
+		t.Fatalf("got wrong files %v", files)
+	}
+	inspect := runTea(t, 0, "inspect", files[2])
+	if !strings.Contains(inspect, "item count     1300") || !strings.Contains(inspect, "description    ticks") || !strings.Contains(inspect, "venue") {
+		t.Fatalf("got wrong last part:\n%s", inspect)
+	}
+	if out := runTea(t, 0, "dump", "-columns", "Volume", "-head", "1", files[1]); out != "Volume\n3000\n" {
+		t.Fatalf("got wrong first item of second part:\n%s", out)
+	}
+
+	runTea(t, 2, "split", input)
+	runTea(t, 2, "split", "-period", "week", input)
+}
+
+func TestSlice(t *testing.T) {
+	input := writeTicks(t, "ticks.tea", 100)
+	output := filepath.Join(t.TempDir(), "slice.tea")
+	out := runTea(t, 0, "slice", "-o", output, "-from", "2020-01-02T09:00:10Z", "-to", "2020-01-02T09:00:20Z", input)
+	if out != "extracted 10 items into " + output + "\n" {
+		t.Fatalf("got wrong output: %s", out)
+	}
+	if out := runTea(t, 0, "dump", "-columns", "Volume", "-tail", "1", output); out != "Volume\n19\n" {
+		t.Fatalf("got wrong last item:\n%s", out)
+	}
+	if inspect := runTea(t, 0, "inspect", output); !strings.Contains(inspect, "resolution     millisecond") {
+		t.Fatalf("time section was not preserved:\n%s", inspect)
+	}
+	runTea(t, 2, "slice", input)
+}
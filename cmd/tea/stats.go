@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/melaurent/goteafiles"
+)
+
+// maxGaps is the number of gaps listed by stats, further ones being
+// counted only
+const maxGaps = 20
+
+type statistics struct {
+	File      string       `json:"file"`
+	ItemCount int64        `json:"itemCount"`
+	Fields    []fieldStats `json:"fields"`
+	Time      *timeStats   `json:"time,omitempty"`
+}
+
+// fieldStats are computed with Welford's algorithm. NaNs and infinities
+// are counted and left out of the other statistics.
+type fieldStats struct {
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`
+	Count  int64   `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	Zeros  int64   `json:"zeros"`
+	NaNs   int64   `json:"nans"`
+	Infs   int64   `json:"infs"`
+	m2     float64
+}
+
+func (fs *fieldStats) add(v float64) {
+	if math.IsNaN(v) {
+		fs.NaNs += 1
+		return
+	}
+	if math.IsInf(v, 0) {
+		fs.Infs += 1
+		return
+	}
+	if v == 0 {
+		fs.Zeros += 1
+	}
+	if fs.Count == 0 || v < fs.Min {
+		fs.Min = v
+	}
+	if fs.Count == 0 || v > fs.Max {
+		fs.Max = v
+	}
+	fs.Count += 1
+	delta := v - fs.Mean
+	fs.Mean += delta / float64(fs.Count)
+	fs.m2 += delta * (v - fs.Mean)
+	if fs.Count > 1 {
+		fs.StdDev = math.Sqrt(fs.m2 / float64(fs.Count - 1))
+	}
+}
+
+type timeStats struct {
+	First    time.Time     `json:"first"`
+	Last     time.Time     `json:"last"`
+	Coverage time.Duration `json:"coverage"`
+	Gap      time.Duration `json:"gapThreshold"`
+	Gaps     []gap         `json:"gaps,omitempty"`
+	GapCount int64         `json:"gapCount"`
+	Unsorted int64         `json:"unsorted"`
+	// Items per second, over the seconds holding items
+	PerSecond   perSecond `json:"itemsPerSecond"`
+	EmptySecond int64     `json:"emptySeconds"`
+	second      time.Time
+	count       int64
+	// Number of seconds holding each count of items. The distinct counts
+	// of n items are fewer than sqrt(2n), whatever the time span.
+	seconds     map[int64]int64
+}
+
+type gap struct {
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Duration time.Duration `json:"duration"`
+}
+
+type perSecond struct {
+	Min    int64 `json:"min"`
+	Median int64 `json:"median"`
+	P99    int64 `json:"p99"`
+	Max    int64 `json:"max"`
+}
+
+func (ts *timeStats) add(t time.Time, first bool) {
+	if first {
+		ts.First = t
+		ts.Last = t
+		ts.second = t.Truncate(time.Second)
+		ts.count = 1
+		ts.seconds = make(map[int64]int64)
+		return
+	}
+	if t.Before(ts.Last) {
+		ts.Unsorted += 1
+		return
+	}
+	if d := t.Sub(ts.Last); d > ts.Gap {
+		ts.GapCount += 1
+		if len(ts.Gaps) < maxGaps {
+			ts.Gaps = append(ts.Gaps, gap{From: ts.Last, To: t, Duration: d})
+		}
+	}
+	ts.Last = t
+	if second := t.Truncate(time.Second); !second.Equal(ts.second) {
+		ts.seconds[ts.count] += 1
+		ts.EmptySecond += int64(second.Sub(ts.second) / time.Second) - 1
+		ts.second = second
+		ts.count = 0
+	}
+	ts.count += 1
+}
+
+func (ts *timeStats) finish() {
+	ts.seconds[ts.count] += 1
+	ts.Coverage = ts.Last.Sub(ts.First)
+	var counts []int64
+	var n int64
+	for count, seconds := range ts.seconds {
+		counts = append(counts, count)
+		n += seconds
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i] < counts[j] })
+	// at returns the count of the second of rank i in increasing order
+	at := func(i int64) int64 {
+		for _, count := range counts {
+			i -= ts.seconds[count]
+			if i < 0 {
+				return count
+			}
+		}
+		return counts[len(counts) - 1]
+	}
+	ts.PerSecond = perSecond{
+		Min: counts[0],
+		Median: at(n / 2),
+		P99: at((n - 1) * 99 / 100),
+		Max: counts[len(counts) - 1],
+	}
+}
+
+func runStats(args []string, stdout io.Writer) error {
+	fs := newFlagSet("stats")
+	asJSON := fs.Bool("json", false, "print JSON instead of text")
+	gapThreshold := fs.Duration("gap", time.Minute, "report gaps between items longer than this")
+	err := parseFlags(fs, args)
+	if err != nil { return err }
+	if fs.NArg() != 1 {
+		return &usageError{msg: "stats takes exactly one file"}
+	}
+
+	tf, err := openFile(fs.Arg(0))
+	if err != nil { return err }
+	defer tf.Close()
+	st, err := computeStats(tf, *gapThreshold)
+	if err != nil { return err }
+	st.File = fs.Arg(0)
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(st)
+	}
+	return st.print(stdout)
+}
+
+// computeStats reads every item of the file once
+func computeStats(tf *goteafiles.TeaFile, gapThreshold time.Duration) (*statistics, error) {
+	st := &statistics{}
+	is := tf.ItemSection()
+	ts := tf.TimeSection()
+	var fields []goteafiles.ItemSectionField
+	for _, f := range is.Fields {
+		if ts.IsTimeField(f) || !numeric(f) {
+			continue
+		}
+		fields = append(fields, f)
+		st.Fields = append(st.Fields, fieldStats{Name: f.Name, Type: goteafiles.FieldTypeName(f.Type)})
+	}
+	withTime := ts != nil && len(ts.Offsets) > 0
+	if withTime {
+		st.Time = &timeStats{Gap: gapThreshold}
+	}
+
+	item := make([]byte, is.Info.ItemSize)
+	for {
+		err := tf.ReadBytes(item)
+		if err == io.EOF {
+			break
+		}
+		if err != nil { return nil, err }
+		for i, f := range fields {
+			v, err := f.Value(item)
+			if err != nil { return nil, err }
+			st.Fields[i].add(toFloat(v))
+		}
+		if withTime {
+			t, err := tf.ItemTime(item)
+			if err != nil { return nil, err }
+			st.Time.add(t, st.ItemCount == 0)
+		}
+		st.ItemCount += 1
+	}
+	if st.Time != nil {
+		if st.ItemCount == 0 {
+			st.Time = nil
+		} else {
+			st.Time.finish()
+		}
+	}
+	return st, nil
+}
+
+// numeric tells whether the values of a field are numbers, custom fields
+// being opaque bytes
+func numeric(f goteafiles.ItemSectionField) bool {
+	switch f.Type {
+	case goteafiles.FIELD_TYPE_INT8, goteafiles.FIELD_TYPE_INT16, goteafiles.FIELD_TYPE_INT32, goteafiles.FIELD_TYPE_INT64,
+		goteafiles.FIELD_TYPE_UINT8, goteafiles.FIELD_TYPE_UINT16, goteafiles.FIELD_TYPE_UINT32, goteafiles.FIELD_TYPE_UINT64,
+		goteafiles.FIELD_TYPE_FLOAT, goteafiles.FIELD_TYPE_DOUBLE, goteafiles.FIELD_TYPE_NET_DECIMAL:
+		return true
+	}
+	return false
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case goteafiles.Decimal:
+		return v.Float64()
+	}
+	return math.NaN()
+}
+
+func (st *statistics) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "file %s, %d items\n\n", st.File, st.ItemCount)
+	fmt.Fprintf(tw, "field\ttype\tcount\tmin\tmax\tmean\tstddev\tzeros\tnans\tinfs\t\n")
+	for _, f := range st.Fields {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%g\t%g\t%g\t%g\t%d\t%d\t%d\t\n",
+			f.Name, f.Type, f.Count, f.Min, f.Max, f.Mean, f.StdDev, f.Zeros, f.NaNs, f.Infs)
+	}
+	err := tw.Flush()
+	if err != nil { return err }
+	if st.Time == nil {
+		return nil
+	}
+	ts := st.Time
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "time from %s to %s, covering %s\n", ts.First.Format(time.RFC3339Nano), ts.Last.Format(time.RFC3339Nano), ts.Coverage)
+	if ts.Unsorted > 0 {
+		fmt.Fprintf(w, "%d items before their previous item\n", ts.Unsorted)
+	}
+	fmt.Fprintf(w, "items per second: min %d, median %d, p99 %d, max %d, %d empty seconds\n",
+		ts.PerSecond.Min, ts.PerSecond.Median, ts.PerSecond.P99, ts.PerSecond.Max, ts.EmptySecond)
+	fmt.Fprintf(w, "%d gaps longer than %s\n", ts.GapCount, ts.Gap)
+	for _, g := range ts.Gaps {
+		fmt.Fprintf(w, "  %s to %s (%s)\n", g.From.Format(time.RFC3339Nano), g.To.Format(time.RFC3339Nano), g.Duration)
+	}
+	if ts.GapCount > int64(len(ts.Gaps)) {
+		fmt.Fprintf(w, "  and %d more\n", ts.GapCount - int64(len(ts.Gaps)))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/melaurent/goteafiles"
+)
+
+func TestStats(t *testing.T) {
+	ticks := writeTicks(t, "ticks.tea", 10)
+	out := runTea(t, 0, "stats", "-json", "-gap", "500ms", ticks)
+	var st statistics
+	err := json.Unmarshal([]byte(out), &st)
+	if err != nil {
+		t.Fatalf("error decoding statistics: %v", err)
+	}
+	if st.ItemCount != 10 || len(st.Fields) != 2 {
+		t.Fatalf("got wrong statistics: %+v", st)
+	}
+	volume := st.Fields[1]
+	if volume.Name != "Volume" || volume.Count != 10 || volume.Min != 0 || volume.Max != 9 || volume.Mean != 4.5 || volume.Zeros != 1 {
+		t.Fatalf("got wrong volume statistics: %+v", volume)
+	}
+	ts := st.Time
+	if ts == nil || !ts.First.Equal(tickStart) || ts.Coverage != 9 * time.Second {
+		t.Fatalf("got wrong time statistics: %+v", ts)
+	}
+	if ts.GapCount != 9 || len(ts.Gaps) != 9 || ts.Gaps[0].Duration != time.Second {
+		t.Fatalf("got wrong gaps: %+v", ts.Gaps)
+	}
+	if ts.PerSecond != (perSecond{Min: 1, Median: 1, P99: 1, Max: 1}) || ts.EmptySecond != 0 {
+		t.Fatalf("got wrong items per second: %+v", ts.PerSecond)
+	}
+
+	out = runTea(t, 0, "stats", acme)
+	if !strings.Contains(out, "2 items") || !strings.Contains(out, "0 gaps longer than 1m0s") {
+		t.Fatalf("got wrong output:\n%s", out)
+	}
+	runTea(t, 2, "stats")
+}
+
+func TestStatsInfinities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dirty.tea")
+	tf, err := goteafiles.Create(path, goteafiles.WithDataType(reflect.TypeOf(Tick{})))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	for _, price := range []float64{math.Inf(1), 1, math.NaN(), 3, math.Inf(-1)} {
+		err = tf.Write(Tick{Price: price})
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	var st statistics
+	err = json.Unmarshal([]byte(runTea(t, 0, "stats", "-json", path)), &st)
+	if err != nil {
+		t.Fatalf("error decoding statistics: %v", err)
+	}
+	price := st.Fields[1]
+	if price.Name != "Price" || price.Count != 2 || price.Min != 1 || price.Max != 3 || price.Mean != 2 || price.NaNs != 1 || price.Infs != 2 {
+		t.Fatalf("got wrong price statistics: %+v", price)
+	}
+}
+
+func TestStatsPerSecond(t *testing.T) {
+	// Ten seconds holding each count of items from 1 to 10, then a
+	// second after an empty one
+	ts := &timeStats{Gap: time.Minute}
+	first := true
+	for s := 0; s < 100; s++ {
+		for i := 0; i <= s % 10; i++ {
+			ts.add(tickStart.Add(time.Duration(s) * time.Second + time.Duration(i) * time.Millisecond), first)
+			first = false
+		}
+	}
+	ts.add(tickStart.Add(101 * time.Second), false)
+	ts.finish()
+	if ts.PerSecond != (perSecond{Min: 1, Median: 5, P99: 10, Max: 10}) || ts.EmptySecond != 1 {
+		t.Fatalf("got wrong items per second: %+v, %d empty seconds", ts.PerSecond, ts.EmptySecond)
+	}
+	if len(ts.seconds) != 10 {
+		t.Fatalf("got %d distinct counts, was expecting 10", len(ts.seconds))
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/melaurent/goteafiles"
+)
+
+type validation struct {
+	File     string        `json:"file"`
+	Valid    bool          `json:"valid"`
+	Problems []problemInfo `json:"problems,omitempty"`
+}
+
+type problemInfo struct {
+	Offset  *int64 `json:"offset,omitempty"`
+	Message string `json:"message"`
+}
+
+// runValidate checks files, exiting with status 0 when they are all
+// valid, 1 when problems are found or a file cannot be read and 2 on
+// usage errors
+func runValidate(args []string, stdout io.Writer) error {
+	fs := newFlagSet("validate")
+	asJSON := fs.Bool("json", false, "print a JSON object per file")
+	err := parseFlags(fs, args)
+	if err != nil { return err }
+	if fs.NArg() == 0 {
+		return &usageError{msg: "no file given"}
+	}
+
+	invalid := 0
+	for _, name := range fs.Args() {
+		val := validation{File: name}
+		problems, err := goteafiles.ValidateFile(name)
+		if err != nil {
+			problems = append(problems, goteafiles.Problem{Offset: -1, Message: err.Error()})
+		}
+		for _, p := range problems {
+			info := problemInfo{Message: p.Message}
+			if p.Offset >= 0 {
+				offset := p.Offset
+				info.Offset = &offset
+			}
+			val.Problems = append(val.Problems, info)
+		}
+		val.Valid = len(problems) == 0
+		if !val.Valid {
+			invalid += 1
+		}
+
+		if *asJSON {
+			err = json.NewEncoder(stdout).Encode(val)
+			if err != nil { return err }
+			continue
+		}
+		if val.Valid {
+			fmt.Fprintf(stdout, "%s: ok\n", name)
+		}
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "%s: %s\n", name, p)
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d files are invalid", invalid, fs.NArg())
+	}
+	return nil
+}
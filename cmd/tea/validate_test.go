@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := writeTicks(t, "ticks.tea", 10)
+	out := runTea(t, 0, "validate", acme, valid)
+	if out != acme + ": ok\n" + valid + ": ok\n" {
+		t.Fatalf("got wrong output:\n%s", out)
+	}
+
+	f, err := os.OpenFile(valid, os.O_APPEND | os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatalf("error opening file: %v", err)
+	}
+	_, err = f.Write([]byte{1, 2})
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		t.Fatalf("error appending to file: %v", err)
+	}
+	out = runTea(t, 1, "validate", "-json", acme, valid)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var val validation
+	err = json.Unmarshal([]byte(lines[1]), &val)
+	if err != nil {
+		t.Fatalf("error decoding validation: %v", err)
+	}
+	if val.Valid || len(val.Problems) != 1 || !strings.Contains(val.Problems[0].Message, "trailing partial item of 2 bytes") {
+		t.Fatalf("got wrong validation: %+v", val)
+	}
+	runTea(t, 1, "validate", "missing.tea")
+	runTea(t, 2, "validate")
+}
@@ -0,0 +1,140 @@
+package goteafiles
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the compression applied to a whole TeaFile. Compressed
+// files are read and written as streams: they cannot be memory mapped,
+// seeked or appended to.
+type Compression int
+
+const (
+	NoCompression Compression = iota
+	Gzip
+	Zstd
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("Compression(%d)", int(c))
+	}
+}
+
+// CompressionOf returns the compression implied by the extension of a file
+// name: .gz for gzip and .zst for zstd
+func CompressionOf(fileName string) Compression {
+	switch {
+	case strings.HasSuffix(fileName, ".gz"):
+		return Gzip
+	case strings.HasSuffix(fileName, ".zst"):
+		return Zstd
+	default:
+		return NoCompression
+	}
+}
+
+// WithCompression compresses a created file whatever its extension
+func WithCompression(c Compression) TeaFileConfig {
+	return configFunc(func (tf *TeaFile) error {
+		tf.compression = c
+		return nil
+	})
+}
+
+// sniffCompression detects the compression of a file from its first bytes
+func sniffCompression(magic []byte) Compression {
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return Gzip
+	case bytes.HasPrefix(magic, zstdMagic):
+		return Zstd
+	default:
+		return NoCompression
+	}
+}
+
+// sniffSeeker detects the compression of the content of r, positioned at
+// its start, and seeks back to the start
+func sniffSeeker(r io.ReadSeeker) (Compression, error) {
+	magic := make([]byte, len(zstdMagic))
+	n, err := io.ReadFull(r, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return NoCompression, err
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	return sniffCompression(magic[:n]), err
+}
+
+func (c Compression) newWriter(w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown compression %v", c)
+	}
+}
+
+func (c Compression) newReader(r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil { return nil, err }
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %v", c)
+	}
+}
+
+// decompress returns a reader of the decompressed content of r, detecting
+// the compression from the first bytes. Uncompressed content is returned
+// as is.
+func decompress(r *bufio.Reader) (io.Reader, Compression, error) {
+	magic, err := r.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, NoCompression, err
+	}
+	c := sniffCompression(magic)
+	if c == NoCompression {
+		return r, c, nil
+	}
+	dr, err := c.newReader(r)
+	if err != nil { return nil, c, err }
+	return bufio.NewReader(dr), c, nil
+}
+
+// closers closes each closer in order, returning the first error
+type closers []io.Closer
+
+func (cs closers) Close() error {
+	var first error
+	for _, c := range cs {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
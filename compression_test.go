@@ -0,0 +1,129 @@
+package goteafiles
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	tests := []struct {
+		fileName    string
+		configs     []TeaFileConfig
+		compression Compression
+	}{
+		{"test.tea.gz", nil, Gzip},
+		{"test.tea.zst", nil, Zstd},
+		{"test.tea", []TeaFileConfig{WithCompression(Zstd)}, Zstd},
+	}
+	for _, test := range tests {
+		configs := append([]TeaFileConfig{
+			WithDataType(reflect.TypeOf(Data{})),
+			WithContentDescription("prices of acme at NYSE"),
+		}, test.configs...)
+		tf, err := Create(test.fileName, configs...)
+		if err != nil {
+			t.Fatalf("%s: error creating TeaFile: %v", test.fileName, err)
+		}
+		for i := 0; i < 100; i++ {
+			err = tf.Write(data)
+			if err != nil {
+				t.Fatalf("%s: error writing data to TeaFile: %v", test.fileName, err)
+			}
+		}
+		err = tf.Close()
+		if err != nil {
+			t.Fatalf("%s: error closing TeaFile: %v", test.fileName, err)
+		}
+
+		tf, err = OpenRead(test.fileName, reflect.TypeOf(Data{}))
+		if err != nil {
+			t.Fatalf("%s: error opening TeaFile: %v", test.fileName, err)
+		}
+		if tf.compression != test.compression {
+			t.Fatalf("%s: got compression %v, was expecting %v", test.fileName, tf.compression, test.compression)
+		}
+		for i := 0; i < 100; i++ {
+			val, err := tf.Read()
+			if err != nil {
+				t.Fatalf("%s: error reading data: %v", test.fileName, err)
+			}
+			if item := val.(reflect.Value).Elem().Interface().(Data); item != data {
+				t.Fatalf("%s: got %v, was expecting %v", test.fileName, item, data)
+			}
+		}
+		if _, err := tf.Read(); err != io.EOF {
+			t.Fatalf("%s: was expecting io.EOF, got %v", test.fileName, err)
+		}
+		if _, err := tf.OpenReadableMapping(); !errors.Is(err, ErrCompressed) {
+			t.Fatalf("%s: was expecting ErrCompressed, got %v", test.fileName, err)
+		}
+		if err := tf.SeekItem(0); !errors.Is(err, ErrCompressed) {
+			t.Fatalf("%s: was expecting ErrCompressed, got %v", test.fileName, err)
+		}
+		err = tf.Close()
+		if err != nil {
+			t.Fatalf("%s: error closing TeaFile: %v", test.fileName, err)
+		}
+
+		f, err := os.Open(test.fileName)
+		if err != nil {
+			t.Fatalf("%s: error opening file: %v", test.fileName, err)
+		}
+		d, err := NewDecoder(f, reflect.TypeOf(Data{}))
+		if err != nil {
+			t.Fatalf("%s: error decoding header: %v", test.fileName, err)
+		}
+		var item Data
+		if err := d.Decode(&item); err != nil || item != data {
+			t.Fatalf("%s: got %v, was expecting %v: %v", test.fileName, item, data, err)
+		}
+		err = f.Close()
+		if err != nil {
+			t.Fatalf("%s: error closing file: %v", test.fileName, err)
+		}
+
+		err = os.Remove(test.fileName)
+		if err != nil {
+			t.Fatalf("%s: error deleting TeaFile: %v", test.fileName, err)
+		}
+	}
+
+	if _, err := OpenWrite("test.tea.gz", reflect.TypeOf(Data{})); !errors.Is(err, ErrCompressed) {
+		t.Fatalf("was expecting ErrCompressed, got %v", err)
+	}
+}
+
+func TestCompressedBuffer(t *testing.T) {
+	tf, err := CreateBuffer(WithDataType(reflect.TypeOf(Tick{})), WithCompression(Zstd))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		err = tf.Write(Tick{Time: int64(i), Price: 100})
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	if !bytes.HasPrefix(tf.Bytes(), zstdMagic) {
+		t.Fatalf("buffer is not compressed")
+	}
+	d, err := NewDecoder(bytes.NewReader(tf.Bytes()), reflect.TypeOf(Tick{}))
+	if err != nil {
+		t.Fatalf("error decoding header: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		var tick Tick
+		err = d.Decode(&tick)
+		if err != nil || tick.Time != int64(i) {
+			t.Fatalf("got item %v, was expecting time %d: %v", tick, i, err)
+		}
+	}
+}
@@ -1,31 +1,46 @@
 package goteafiles
 
 import (
+	"fmt"
 	"reflect"
 )
 
 type TeaFileConfig func(*TeaFile)
 
+// configFunc makes a TeaFileConfig of a config that can fail. Its error is
+// recorded on the TeaFile, returned by Create and OpenRead, and the
+// configs following a failed one are skipped.
+func configFunc(config func(*TeaFile) error) TeaFileConfig {
+	return func (tf *TeaFile) {
+		if tf.configErr == nil {
+			tf.configErr = config(tf)
+		}
+	}
+}
+
 func WithDataType(typ reflect.Type) TeaFileConfig {
 	// TODO populate time section if there is time types in the
 	// data type
-	return func (tf *TeaFile) {
+	return configFunc(func (tf *TeaFile) error {
 		tf.dataType = typ
+		fields, err := flattenType(typ)
+		if err != nil { return err }
 		itemSection := ItemSection{}
 		itemSection.Info.ItemSize = int32(typ.Size())
-		itemSection.Info.FieldCount = int32(typ.NumField())
+		itemSection.Info.FieldCount = int32(len(fields))
 		itemSection.Info.ItemTypeName = typ.Name()
-		for i := 0; i < typ.NumField(); i++ {
-			dataField := typ.Field(i)
+		for i, dataField := range fields {
+			fieldType, _ := fieldTypeOf(dataField.Type)
 			itemField := ItemSectionField{}
 			itemField.Name = dataField.Name
 			itemField.Offset = int32(dataField.Offset)
 			itemField.Index = int32(i)
-			itemField.Type = kindToFieldType[dataField.Type.Kind()]
+			itemField.Type = fieldType
 			itemSection.Fields = append(itemSection.Fields, itemField)
 		}
 		tf.itemSection = &itemSection
-	}
+		return nil
+	})
 }
 
 func WithContentDescription(description string) TeaFileConfig {
@@ -36,7 +51,14 @@ func WithContentDescription(description string) TeaFileConfig {
 	}
 }
 
+// WithNameValues stores the name values sorted by name, so that the
+// same map always produces the same file
 func WithNameValues(nameValues map[string]interface{}) TeaFileConfig {
+	return WithOrderedNameValues(NameValuesFromMap(nameValues))
+}
+
+// WithOrderedNameValues stores the name values in the given order
+func WithOrderedNameValues(nameValues NameValues) TeaFileConfig {
 	return func (tf *TeaFile) {
 		tf.nameValueSection = &NameValueSection{
 			NameValues: nameValues,
@@ -45,9 +67,18 @@ func WithNameValues(nameValues map[string]interface{}) TeaFileConfig {
 }
 
 func WithTimeFields(epoch int64, ticksPerDay int64, indexes []int32) TeaFileConfig {
-	return func (tf *TeaFile) {
+	return configFunc(func (tf *TeaFile) error {
+		if tf.itemSection == nil {
+			return fmt.Errorf("time fields require an item section")
+		}
+		if ticksPerDay <= 0 {
+			return fmt.Errorf("time fields require a positive number of ticks per day, got %d", ticksPerDay)
+		}
 		var offsets []int32
 		for _, idx := range indexes {
+			if idx < 0 || int(idx) >= len(tf.itemSection.Fields) {
+				return fmt.Errorf("no field with index %d", idx)
+			}
 			offsets = append(offsets, tf.itemSection.Fields[idx].Offset)
 		}
 		tf.timeSection = &TimeSection{
@@ -56,5 +87,84 @@ func WithTimeFields(epoch int64, ticksPerDay int64, indexes []int32) TeaFileConf
 			Count: int32(len(offsets)),
 			Offsets: offsets,
 		}
+		return nil
+	})
+}
+
+// WithPackedLayout lays the fields of the data type out without padding,
+// as C and C++ producers do with #pragma pack(1). It must follow
+// WithDataType and precede WithTimeFields. Items are then encoded field by
+// field, and the file cannot be memory mapped into the data type.
+func WithPackedLayout() TeaFileConfig {
+	return configFunc(func (tf *TeaFile) error {
+		if tf.itemSection == nil {
+			return fmt.Errorf("packed layout requires an item section")
+		}
+		if tf.timeSection != nil {
+			return fmt.Errorf("packed layout must be set before time fields")
+		}
+		fields, err := flattenType(tf.dataType)
+		if err != nil { return err }
+		packItemSection(tf.itemSection, fields)
+		tf.explicitLayout = true
+		return nil
+	})
+}
+
+// WithExplicitLayout makes the offsets of the item section authoritative
+// when opening a file. The fields of the data type are matched to the
+// fields of the file by name, and items are encoded and decoded field by
+// field when the layouts differ.
+func WithExplicitLayout() TeaFileConfig {
+	return func (tf *TeaFile) {
+		tf.explicitLayout = true
 	}
-}
\ No newline at end of file
+}
+
+// WithSectionsOf describes the items as src does, copying its item
+// section, time section, name values and content description, as well as
+// its chunk compression, encodings and checksum block size. Items are
+// then written with WriteBytes.
+func WithSectionsOf(src *TeaFile) TeaFileConfig {
+	return configFunc(func (tf *TeaFile) error {
+		if src.itemSection == nil {
+			return ErrNoItemSection
+		}
+		is := *src.itemSection
+		is.Fields = append([]ItemSectionField{}, is.Fields...)
+		tf.dataType = nil
+		tf.itemSection = &is
+		if src.timeSection != nil {
+			ts := *src.timeSection
+			ts.Offsets = append([]int32{}, ts.Offsets...)
+			tf.timeSection = &ts
+		}
+		if src.nameValueSection != nil {
+			tf.nameValueSection = &NameValueSection{
+				NameValues: append(NameValues{}, src.nameValueSection.NameValues...),
+			}
+		}
+		if src.contentDescriptionSection != nil {
+			cd := *src.contentDescriptionSection
+			tf.contentDescriptionSection = &cd
+		}
+		if src.chunkSection != nil {
+			tf.chunkSection = &ChunkSection{
+				Compression: src.chunkSection.Compression,
+				ChunkItems: src.chunkSection.ChunkItems,
+			}
+		}
+		if src.encodingSection != nil {
+			tf.encodingSection = &EncodingSection{
+				Encodings: append([]FieldEncoding{}, src.encodingSection.Encodings...),
+			}
+		}
+		if src.checksumSection != nil {
+			tf.checksumSection = &ChecksumSection{
+				Algorithm: src.checksumSection.Algorithm,
+				BlockSize: src.checksumSection.BlockSize,
+			}
+		}
+		return nil
+	})
+}
@@ -1,10 +1,13 @@
 package goteafiles
 
 import (
+	"bytes"
+	"errors"
 	uuid "github.com/satori/go.uuid"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestWithDataType(t *testing.T) {
@@ -51,9 +54,11 @@ func TestWithContentDescription(t *testing.T) {
 	fixture := ContentDescriptionSection{
 		ContentDescription: "prices of acme at NYSE",
 	}
-	tf, err := Create(
-		"test.tea",
-		WithContentDescription("prices of acme at NYSE"))
+	// Configs defined by callers keep working
+	acme := func(tf *TeaFile) {
+		WithContentDescription("prices of acme at NYSE")(tf)
+	}
+	tf, err := Create("test.tea", acme)
 	if err != nil {
 		t.Fatalf("error creating TeaFile: %v", err)
 	}
@@ -70,12 +75,12 @@ func TestWithNameValues(t *testing.T) {
 	// Test that a config creates the correct name value section
 	id := uuid.NewV1()
 	fixture := NameValueSection{
-		NameValues: map[string]interface{} {
-			"a": int32(1),
-			"b": "c",
-			"c": float64(1.2),
-			"d": id,
-			"e": uint64(100),
+		NameValues: NameValues{
+			{Name: "a", Value: int32(1)},
+			{Name: "b", Value: "c"},
+			{Name: "c", Value: float64(1.2)},
+			{Name: "d", Value: id},
+			{Name: "e", Value: uint64(100)},
 		},
 	}
 	tf, err := Create(
@@ -121,4 +126,227 @@ func TestWithTimeFields(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error deleting TeaFile: %v", err)
 	}
-}
\ No newline at end of file
+
+	// The error of a config is returned, the following ones are skipped
+	_, err = CreateBuffer(WithTimeFields(719162, 86400000, []int32{0}), WithPackedLayout())
+	if err == nil || err.Error() != "time fields require an item section" {
+		t.Fatalf("was expecting the time fields error, got %v", err)
+	}
+}
+
+func TestWithNameValuesCoercion(t *testing.T) {
+	// Test that convenient Go types are stored as their spec types
+	at := time.Date(2011, 3, 4, 9, 0, 0, 0, time.UTC)
+	nvs := NameValues{
+		{Name: "int", Value: 2},
+		{Name: "int64", Value: int64(-3)},
+		{Name: "bool", Value: true},
+		{Name: "time", Value: at},
+		{Name: "bytes", Value: []byte("acme")},
+		{Name: "uint32", Value: uint32(7)},
+		{Name: "large", Value: int64(1) << 40},
+	}
+	tf, err := Create(
+		"test.tea",
+		WithDataType(reflect.TypeOf(Data{})),
+		WithOrderedNameValues(nvs))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	// The caller's name values are left as they are
+	if nvs[0].Value != 2 || nvs[2].Value != true {
+		t.Fatalf("name values were modified: %v", nvs)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	tf, err = OpenRead("test.tea", reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	if v, err := tf.GetInt32("int"); err != nil || v != 2 {
+		t.Fatalf("got wrong int value: %v %v", v, err)
+	}
+	if v, err := tf.GetInt32("int64"); err != nil || v != -3 {
+		t.Fatalf("got wrong int64 value: %v %v", v, err)
+	}
+	if v, err := tf.GetInt32("bool"); err != nil || v != 1 {
+		t.Fatalf("got wrong bool value: %v %v", v, err)
+	}
+	if v, err := tf.GetString("time"); err != nil || v != "2011-03-04T09:00:00Z" {
+		t.Fatalf("got wrong time value: %v %v", v, err)
+	}
+	if v, err := tf.GetString("bytes"); err != nil || v != "acme" {
+		t.Fatalf("got wrong bytes value: %v %v", v, err)
+	}
+	if v, err := tf.GetUint64("uint32"); err != nil || v != 7 {
+		t.Fatalf("got wrong uint32 value: %v %v", v, err)
+	}
+	if v, err := tf.GetUint64("large"); err != nil || v != 1 << 40 {
+		t.Fatalf("got wrong large value: %v %v", v, err)
+	}
+	if _, err := tf.GetString("int"); err == nil {
+		t.Fatalf("was expecting an error for a wrong type")
+	}
+	if _, err := tf.GetString("missing"); err == nil {
+		t.Fatalf("was expecting an error for a missing name")
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+
+	// Unsupported values are rejected before creating the file
+	_, err = Create(
+		"test.tea",
+		WithNameValues(map[string]interface{}{"a": []int{1}}))
+	if err == nil {
+		t.Fatalf("was expecting an error for an unsupported value")
+	}
+	_, err = Create(
+		"test.tea",
+		WithNameValues(map[string]interface{}{"a": -int64(1) << 40}))
+	if err == nil {
+		t.Fatalf("was expecting an error for an overflowing value")
+	}
+	if _, err := os.Stat("test.tea"); !os.IsNotExist(err) {
+		t.Fatalf("file should not have been created")
+	}
+	var buf bytes.Buffer
+	err = writeSection(&buf, nativeEndian, &NameValueSection{NameValues: NameValues{{Name: "a", Value: []int{1}}}})
+	if !errors.Is(err, ErrUnsupportedType) || buf.Len() != 0 {
+		t.Fatalf("was expecting ErrUnsupportedType before writing the section, got %v", err)
+	}
+}
+
+func TestWithDataTypeFieldTypes(t *testing.T) {
+	type Quote struct {
+		Time  uint64
+		Side  Char
+		Price Decimal
+	}
+	tf, err := Create(
+		"test.tea",
+		WithDataType(reflect.TypeOf(Quote{})))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	types := []int32{FIELD_TYPE_UINT64, FIELD_TYPE_UINT16, FIELD_TYPE_NET_DECIMAL}
+	for i, field := range tf.itemSection.Fields {
+		if field.Type != types[i] {
+			t.Fatalf("got field type %d for %s, was expecting %d", field.Type, field.Name, types[i])
+		}
+	}
+	price, err := ParseDecimal("101.25")
+	if err != nil {
+		t.Fatalf("error parsing decimal: %v", err)
+	}
+	err = tf.Write(Quote{Time: 1, Side: 'B', Price: price})
+	if err != nil {
+		t.Fatalf("error writing data to TeaFile: %v", err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	tf, err = OpenRead("test.tea", reflect.TypeOf(Quote{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	val, err := tf.Read()
+	if err != nil {
+		t.Fatalf("error reading data: %v", err)
+	}
+	quote := val.(reflect.Value).Elem().Interface().(Quote)
+	if quote.Side != 'B' || quote.Price.String() != "101.25" {
+		t.Fatalf("got wrong quote: %v", quote)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+
+	// Kinds without a field type are rejected
+	type Flag struct {
+		Time uint64
+		Set  bool
+	}
+	_, err = Create(
+		"test.tea",
+		WithDataType(reflect.TypeOf(Flag{})))
+	if err == nil {
+		t.Fatalf("was expecting an error for a bool field")
+	}
+}
+
+func TestWithDataTypeNested(t *testing.T) {
+	type Quote struct {
+		Price float64
+		Size  uint32
+	}
+	type Book struct {
+		Time   uint64
+		Bid    Quote
+		Levels [2]Quote
+		Depth  [3]uint8
+	}
+	fixture := []ItemSectionField{
+		{Index: 0, Type: FIELD_TYPE_UINT64, Offset: 0, Name: "Time"},
+		{Index: 1, Type: FIELD_TYPE_DOUBLE, Offset: 8, Name: "Bid.Price"},
+		{Index: 2, Type: FIELD_TYPE_UINT32, Offset: 16, Name: "Bid.Size"},
+		{Index: 3, Type: FIELD_TYPE_DOUBLE, Offset: 24, Name: "Levels[0].Price"},
+		{Index: 4, Type: FIELD_TYPE_UINT32, Offset: 32, Name: "Levels[0].Size"},
+		{Index: 5, Type: FIELD_TYPE_DOUBLE, Offset: 40, Name: "Levels[1].Price"},
+		{Index: 6, Type: FIELD_TYPE_UINT32, Offset: 48, Name: "Levels[1].Size"},
+		{Index: 7, Type: FIELD_TYPE_UINT8, Offset: 56, Name: "Depth[0]"},
+		{Index: 8, Type: FIELD_TYPE_UINT8, Offset: 57, Name: "Depth[1]"},
+		{Index: 9, Type: FIELD_TYPE_UINT8, Offset: 58, Name: "Depth[2]"},
+	}
+	tf, err := Create(
+		"test.tea",
+		WithDataType(reflect.TypeOf(Book{})))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	if !reflect.DeepEqual(tf.itemSection.Fields, fixture) {
+		t.Fatalf("got different item fields: %v", tf.itemSection.Fields)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	tf, err = OpenRead("test.tea", reflect.TypeOf(Book{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	type FlatBook struct {
+		Time   uint64
+		Bid    Quote
+		Levels [3]Quote
+	}
+	_, err = OpenRead("test.tea", reflect.TypeOf(FlatBook{}))
+	if err == nil {
+		t.Fatalf("was expecting an error for a different type")
+	}
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+}
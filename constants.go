@@ -10,29 +10,77 @@ const (
 	CONTENT_DESCRIPTION_SECTION_ID int32 = 0x80
 	NAME_VALUE_SECTION_ID          int32 = 0x81
 	TIME_SECTION_ID                int32 = 0x40
+	// Custom sections, not defined by the TeaFiles specification
+	CHUNK_SECTION_ID               int32 = 0x1001
+	ENCODING_SECTION_ID            int32 = 0x1002
+	CHECKSUM_SECTION_ID            int32 = 0x1003
+
+	CHECKSUM_CRC32C                int32 = 1
 
 	NAME_VALUE_INT32               int32 = 1
 	NAME_VALUE_DOUBLE              int32 = 2
 	NAME_VALUE_TEXT                int32 = 3
 	NAME_VALUE_UUID                int32 = 4
 	NAME_VALUE_UINT64              int32 = 5
+
+	FIELD_TYPE_INT8                int32 = 1
+	FIELD_TYPE_INT16               int32 = 2
+	FIELD_TYPE_INT32               int32 = 3
+	FIELD_TYPE_INT64               int32 = 4
+	FIELD_TYPE_UINT8               int32 = 5
+	FIELD_TYPE_UINT16              int32 = 6
+	FIELD_TYPE_UINT32              int32 = 7
+	FIELD_TYPE_UINT64              int32 = 8
+	FIELD_TYPE_FLOAT               int32 = 9
+	FIELD_TYPE_DOUBLE              int32 = 10
+	FIELD_TYPE_CUSTOM              int32 = 0x100
+	FIELD_TYPE_NET_DECIMAL         int32 = 0x200
 )
 
 var fieldTypeToKind = map[int32]reflect.Kind {
-	1 : reflect.Int8,
-	2 : reflect.Int16,
-	3 : reflect.Int32,
-	4 : reflect.Int64,
-	5 : reflect.Uint8,
-	6 : reflect.Uint16,
-	7 : reflect.Uint32,
-	8 : reflect.Uint64,
-	9 : reflect.Float32,
-	10: reflect.Float64,
+	FIELD_TYPE_INT8  : reflect.Int8,
+	FIELD_TYPE_INT16 : reflect.Int16,
+	FIELD_TYPE_INT32 : reflect.Int32,
+	FIELD_TYPE_INT64 : reflect.Int64,
+	FIELD_TYPE_UINT8 : reflect.Uint8,
+	FIELD_TYPE_UINT16: reflect.Uint16,
+	FIELD_TYPE_UINT32: reflect.Uint32,
+	FIELD_TYPE_UINT64: reflect.Uint64,
+	FIELD_TYPE_FLOAT : reflect.Float32,
+	FIELD_TYPE_DOUBLE: reflect.Float64,
 }
 
 var kindToFieldType = make(map[reflect.Kind]int32)
 
+var fieldTypeNames = map[int32]string {
+	FIELD_TYPE_INT8       : "Int8",
+	FIELD_TYPE_INT16      : "Int16",
+	FIELD_TYPE_INT32      : "Int32",
+	FIELD_TYPE_INT64      : "Int64",
+	FIELD_TYPE_UINT8      : "UInt8",
+	FIELD_TYPE_UINT16     : "UInt16",
+	FIELD_TYPE_UINT32     : "UInt32",
+	FIELD_TYPE_UINT64     : "UInt64",
+	FIELD_TYPE_FLOAT      : "Float",
+	FIELD_TYPE_DOUBLE     : "Double",
+	FIELD_TYPE_CUSTOM     : "Custom",
+	FIELD_TYPE_NET_DECIMAL: "NetDecimal",
+}
+
+var fieldTypeSizes = map[int32]int32 {
+	FIELD_TYPE_INT8       : 1,
+	FIELD_TYPE_INT16      : 2,
+	FIELD_TYPE_INT32      : 4,
+	FIELD_TYPE_INT64      : 8,
+	FIELD_TYPE_UINT8      : 1,
+	FIELD_TYPE_UINT16     : 2,
+	FIELD_TYPE_UINT32     : 4,
+	FIELD_TYPE_UINT64     : 8,
+	FIELD_TYPE_FLOAT      : 4,
+	FIELD_TYPE_DOUBLE     : 8,
+	FIELD_TYPE_NET_DECIMAL: 16,
+}
+
 var typeToNameValueType = map[string]int32 {
 	reflect.TypeOf(int32(1)).String()    : 1,
 	reflect.TypeOf(float64(1.2)).String(): 2,
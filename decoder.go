@@ -0,0 +1,88 @@
+package goteafiles
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// Decoder reads a TeaFile from a forward-only stream, such as stdin, an
+// HTTP body or a gzip stream. The header is parsed without seeking, and
+// items are then decoded one after the other.
+type Decoder struct {
+	tf        *TeaFile
+	buf       []byte
+	remaining int64
+}
+
+// NewDecoder parses the header of the TeaFile read from r, decompressing
+// gzip and zstd streams transparently. The data type may be nil, in which
+// case items can only be read with DecodeBytes.
+func NewDecoder(r io.Reader, dataType reflect.Type, configs ...TeaFileConfig) (*Decoder, error) {
+	dr, c, err := decompress(bufio.NewReader(r))
+	if err != nil { return nil, err }
+	tf := &TeaFile{
+		mode: os.O_RDONLY,
+		reader: dr,
+		dataType: dataType,
+		compression: c,
+	}
+	err = tf.open(configs)
+	if err != nil { return nil, err }
+
+	d := &Decoder{
+		tf: tf,
+		buf: make([]byte, tf.itemSection.Info.ItemSize),
+		remaining: -1,
+	}
+	if tf.header.ItemEnd != 0 && tf.chunkReader == nil {
+		d.remaining = (tf.header.ItemEnd - tf.header.ItemStart) / int64(tf.itemSection.Info.ItemSize)
+	}
+	return d, nil
+}
+
+// TeaFile returns the TeaFile holding the sections of the stream. Its
+// operations requiring random access return ErrNotSeekable.
+func (d *Decoder) TeaFile() *TeaFile {
+	return d.tf
+}
+
+// DecodeBytes returns the bytes of the next item, in file layout. The
+// returned slice is only valid until the next call. It returns io.EOF
+// after the last item, and io.ErrUnexpectedEOF if the stream ends inside
+// an item.
+func (d *Decoder) DecodeBytes() ([]byte, error) {
+	if d.remaining == 0 {
+		return nil, io.EOF
+	}
+	if d.tf.chunkReader != nil {
+		err := d.tf.chunkReader.readItem(d.tf, d.buf)
+		if err != nil { return nil, err }
+		return d.buf, nil
+	}
+	_, err := io.ReadFull(d.tf.reader, d.buf)
+	if err != nil { return nil, err }
+	if d.remaining > 0 {
+		d.remaining -= 1
+	}
+	return d.buf, nil
+}
+
+// Decode stores the next item in the value pointed to by v, which must be
+// a pointer to the data type
+func (d *Decoder) Decode(v interface{}) error {
+	val := reflect.ValueOf(v)
+	if d.tf.dataType == nil || val.Kind() != reflect.Ptr || val.Elem().Type() != d.tf.dataType {
+		return fmt.Errorf("%w: was expecting *%s, got %T", ErrTypeMismatch, d.tf.dataType, v)
+	}
+	b, err := d.DecodeBytes()
+	if err != nil { return err }
+	if d.tf.layout != nil {
+		d.tf.layout.decode(val.Pointer(), b)
+	} else {
+		copy(itemBytes(val.Pointer(), len(b)), b)
+	}
+	return nil
+}
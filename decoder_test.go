@@ -0,0 +1,62 @@
+package goteafiles
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	// Compress the fixture through a pipe, so that neither the gzip
+	// stream nor the pipe can seek
+	pr, pw := io.Pipe()
+	go func() {
+		f, err := os.Open("test-fixtures/acme.tea")
+		if err != nil {
+			_ = pw.CloseWithError(err)
+			return
+		}
+		defer f.Close()
+		zw := gzip.NewWriter(pw)
+		_, err = io.Copy(zw, f)
+		if err == nil {
+			err = zw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	zr, err := gzip.NewReader(pr)
+	if err != nil {
+		t.Fatalf("error opening gzip stream: %v", err)
+	}
+
+	d, err := NewDecoder(zr, reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error decoding header: %v", err)
+	}
+	if d.TeaFile().GetNameValues()["url"] != "www.acme.com" {
+		t.Fatalf("got wrong name values: %v", d.TeaFile().GetNameValues())
+	}
+	if err := d.TeaFile().SeekItem(1); err != ErrNotSeekable {
+		t.Fatalf("was expecting ErrNotSeekable, got %v", err)
+	}
+	var items []Data
+	for {
+		var item Data
+		err := d.Decode(&item)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error decoding item: %v", err)
+		}
+		items = append(items, item)
+	}
+	if len(items) != 2 || items[0] != data || items[1] != data {
+		t.Fatalf("got wrong items: %v", items)
+	}
+	if err := d.Decode(Data{}); err == nil {
+		t.Fatalf("was expecting an error decoding into a non pointer")
+	}
+}
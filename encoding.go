@@ -0,0 +1,358 @@
+package goteafiles
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// FieldEncoding is the encoding of a field in an encoded item area
+type FieldEncoding int32
+
+const (
+	// RawEncoding stores the field bytes as they are
+	RawEncoding FieldEncoding = iota
+	// DeltaEncoding stores the difference with the previous item as a
+	// zigzag varint
+	DeltaEncoding
+	// DeltaOfDeltaEncoding stores the difference between the delta with
+	// the previous item and the previous delta as a zigzag varint, which
+	// takes a single byte for regularly spaced timestamps
+	DeltaOfDeltaEncoding
+	// XorEncoding stores the bits of the field xored with those of the
+	// previous item, as a byte holding the number of trailing zero bytes
+	// and of meaningful bytes followed by the meaningful bytes. Repeated
+	// floating point values take a single byte, and close values skip the
+	// sign and exponent bytes they share.
+	XorEncoding
+)
+
+func (e FieldEncoding) String() string {
+	switch e {
+	case RawEncoding:
+		return "raw"
+	case DeltaEncoding:
+		return "delta"
+	case DeltaOfDeltaEncoding:
+		return "delta-of-delta"
+	case XorEncoding:
+		return "xor"
+	default:
+		return fmt.Sprintf("FieldEncoding(%d)", int32(e))
+	}
+}
+
+// EncodingSection declares the encoding of each item field. Encoded items
+// are stored in chunks, as described by the ChunkSection, and the
+// encoding state is reset at the start of each chunk.
+type EncodingSection struct {
+	Encodings []FieldEncoding
+}
+
+func (es *EncodingSection) ID() int32 {
+	return ENCODING_SECTION_ID
+}
+
+func (es *EncodingSection) Read(r io.Reader, order binary.ByteOrder) error {
+	count, err := readCount(r, order, 4)
+	if err != nil { return err }
+	es.Encodings = make([]FieldEncoding, count)
+	return binary.Read(r, order, es.Encodings)
+}
+
+func (es *EncodingSection) Write(w io.Writer, order binary.ByteOrder) error {
+	err := binary.Write(w, order, int32(len(es.Encodings)))
+	if err != nil { return err }
+	return binary.Write(w, order, es.Encodings)
+}
+
+func (es *EncodingSection) Size() int64 {
+	// Count
+	var size int64 = 4
+	// Encodings
+	size += 4 * int64(len(es.Encodings))
+	return size
+}
+
+// defaultChunkItems is the number of items per chunk of files that are
+// encoded without block compression
+const defaultChunkItems = 4096
+
+// WithDeltaEncoding encodes the item area to make it much smaller, at the
+// cost of memory mapping. By default time fields are encoded with
+// DeltaOfDeltaEncoding, integers with DeltaEncoding, floating point
+// numbers with XorEncoding and decimals are kept raw. Encodings can be
+// overridden by field name. Items are stored in chunks, compressed if
+// WithChunkCompression is given, so that seeking stays possible.
+func WithDeltaEncoding(overrides map[string]FieldEncoding) TeaFileConfig {
+	return configFunc(func (tf *TeaFile) error {
+		tf.encodingOverrides = overrides
+		if tf.encodingOverrides == nil {
+			tf.encodingOverrides = make(map[string]FieldEncoding)
+		}
+		return nil
+	})
+}
+
+// buildEncodingSection builds the encoding section once all configs are
+// applied, so that it knows the time fields
+func (tf *TeaFile) buildEncodingSection() (*EncodingSection, error) {
+	if tf.itemSection == nil {
+		return nil, fmt.Errorf("delta encoding requires an item section")
+	}
+	timeOffsets := make(map[int32]bool)
+	if tf.timeSection != nil {
+		for _, offset := range tf.timeSection.Offsets {
+			timeOffsets[offset] = true
+		}
+	}
+	es := &EncodingSection{}
+	used := 0
+	for _, field := range tf.itemSection.Fields {
+		encoding, ok := tf.encodingOverrides[field.Name]
+		if ok {
+			used += 1
+		} else {
+			switch field.Type {
+			case FIELD_TYPE_FLOAT, FIELD_TYPE_DOUBLE:
+				encoding = XorEncoding
+			case FIELD_TYPE_NET_DECIMAL:
+				encoding = RawEncoding
+			default:
+				encoding = DeltaEncoding
+				if timeOffsets[field.Offset] {
+					encoding = DeltaOfDeltaEncoding
+				}
+			}
+		}
+		if encoding != RawEncoding && field.Type == FIELD_TYPE_NET_DECIMAL {
+			return nil, fmt.Errorf("field %s: decimals can only be raw encoded", field.Name)
+		}
+		if encoding < RawEncoding || encoding > XorEncoding {
+			return nil, fmt.Errorf("field %s: unknown encoding %v", field.Name, encoding)
+		}
+		es.Encodings = append(es.Encodings, encoding)
+	}
+	if used != len(tf.encodingOverrides) {
+		return nil, fmt.Errorf("encoding overrides name fields that do not exist")
+	}
+	return es, nil
+}
+
+// itemCodec encodes and decodes the items of a chunk
+type itemCodec struct {
+	fields    []codecField
+	itemSize  int
+	prev      []uint64
+	prevDelta []uint64
+}
+
+type codecField struct {
+	offset   int
+	size     int
+	signed   bool
+	encoding FieldEncoding
+}
+
+func newItemCodec(is *ItemSection, es *EncodingSection) (*itemCodec, error) {
+	// Items without fields would be decoded without consuming anything
+	if len(is.Fields) == 0 {
+		return nil, fmt.Errorf("encoded items without fields: %w", ErrCorrupt)
+	}
+	if len(es.Encodings) != len(is.Fields) {
+		return nil, fmt.Errorf("%d field encodings for %d fields", len(es.Encodings), len(is.Fields))
+	}
+	codec := &itemCodec{itemSize: int(is.Info.ItemSize)}
+	for i, field := range is.Fields {
+		size, ok := fieldTypeSizes[field.Type]
+		if !ok {
+			return nil, fmt.Errorf("field %s: %w: %s", field.Name, ErrUnsupportedType, FieldTypeName(field.Type))
+		}
+		if field.Offset < 0 || field.Offset + size > is.Info.ItemSize {
+			return nil, fmt.Errorf("field %s at offset %d is outside items of %d bytes: %w", field.Name, field.Offset, is.Info.ItemSize, ErrCorrupt)
+		}
+		encoding := es.Encodings[i]
+		if encoding != RawEncoding && size > 8 {
+			return nil, fmt.Errorf("field %s: cannot %v encode %d bytes", field.Name, encoding, size)
+		}
+		codec.fields = append(codec.fields, codecField{
+			offset: int(field.Offset),
+			size: int(size),
+			signed: field.Type >= FIELD_TYPE_INT8 && field.Type <= FIELD_TYPE_INT64,
+			encoding: encoding,
+		})
+	}
+	codec.prev = make([]uint64, len(codec.fields))
+	codec.prevDelta = make([]uint64, len(codec.fields))
+	return codec, nil
+}
+
+func (c *itemCodec) reset() {
+	for i := range c.prev {
+		c.prev[i] = 0
+		c.prevDelta[i] = 0
+	}
+}
+
+// load returns the field as 64 bits, sign extended for signed integers
+func (f *codecField) load(item []byte) uint64 {
+	b := item[f.offset:f.offset + f.size]
+	switch f.size {
+	case 1:
+		if f.signed { return uint64(int64(int8(b[0]))) }
+		return uint64(b[0])
+	case 2:
+		v := nativeEndian.Uint16(b)
+		if f.signed { return uint64(int64(int16(v))) }
+		return uint64(v)
+	case 4:
+		v := nativeEndian.Uint32(b)
+		if f.signed { return uint64(int64(int32(v))) }
+		return uint64(v)
+	default:
+		return nativeEndian.Uint64(b)
+	}
+}
+
+// store writes the low bits of v to the field
+func (f *codecField) store(item []byte, v uint64) {
+	b := item[f.offset:f.offset + f.size]
+	switch f.size {
+	case 1:
+		b[0] = uint8(v)
+	case 2:
+		nativeEndian.PutUint16(b, uint16(v))
+	case 4:
+		nativeEndian.PutUint32(b, uint32(v))
+	default:
+		nativeEndian.PutUint64(b, v)
+	}
+}
+
+func zigzag(v uint64) uint64 {
+	return uint64((int64(v) << 1) ^ (int64(v) >> 63))
+}
+
+func unzigzag(v uint64) uint64 {
+	return (v >> 1) ^ -(v & 1)
+}
+
+// appendXor appends the trailing zero bytes and meaningful bytes count of
+// x, followed by its meaningful bytes
+func appendXor(dst []byte, x uint64) []byte {
+	if x == 0 {
+		return append(dst, 0)
+	}
+	trailing := bits.TrailingZeros64(x) / 8
+	n := 8 - bits.LeadingZeros64(x) / 8 - trailing
+	dst = append(dst, byte(trailing << 4 | n))
+	x >>= 8 * uint(trailing)
+	for i := 0; i < n; i++ {
+		dst = append(dst, byte(x))
+		x >>= 8
+	}
+	return dst
+}
+
+// readXor reads a value written by appendXor, returning the number of
+// bytes read or 0 if src is invalid
+func readXor(src []byte) (uint64, int) {
+	if len(src) == 0 {
+		return 0, 0
+	}
+	trailing, n := int(src[0] >> 4), int(src[0] & 0xf)
+	if trailing + n > 8 || len(src) < 1 + n {
+		return 0, 0
+	}
+	var x uint64
+	for i := n; i > 0; i-- {
+		x = x << 8 | uint64(src[i])
+	}
+	return x << (8 * uint(trailing)), 1 + n
+}
+
+// encode appends the encoding of the items to dst
+func (c *itemCodec) encode(dst []byte, items []byte) []byte {
+	c.reset()
+	var tmp [binary.MaxVarintLen64]byte
+	for start := 0; start + c.itemSize <= len(items); start += c.itemSize {
+		item := items[start:start + c.itemSize]
+		for i := range c.fields {
+			f := &c.fields[i]
+			if f.encoding == RawEncoding {
+				dst = append(dst, item[f.offset:f.offset + f.size]...)
+				continue
+			}
+			v := f.load(item)
+			if f.encoding == XorEncoding {
+				dst = appendXor(dst, v ^ c.prev[i])
+				c.prev[i] = v
+				continue
+			}
+			var encoded uint64
+			switch f.encoding {
+			case DeltaEncoding:
+				encoded = zigzag(v - c.prev[i])
+			case DeltaOfDeltaEncoding:
+				delta := v - c.prev[i]
+				encoded = zigzag(delta - c.prevDelta[i])
+				c.prevDelta[i] = delta
+			}
+			c.prev[i] = v
+			n := binary.PutUvarint(tmp[:], encoded)
+			dst = append(dst, tmp[:n]...)
+		}
+	}
+	return dst
+}
+
+// decode appends the items decoded from src to dst
+func (c *itemCodec) decode(dst []byte, src []byte) ([]byte, error) {
+	c.reset()
+	for len(src) > 0 {
+		start := len(dst)
+		for i := 0; i < c.itemSize; i++ {
+			dst = append(dst, 0)
+		}
+		item := dst[start:]
+		for i := range c.fields {
+			f := &c.fields[i]
+			if f.encoding == RawEncoding {
+				if len(src) < f.size {
+					return nil, io.ErrUnexpectedEOF
+				}
+				copy(item[f.offset:f.offset + f.size], src[:f.size])
+				src = src[f.size:]
+				continue
+			}
+			if f.encoding == XorEncoding {
+				x, n := readXor(src)
+				if n == 0 {
+					return nil, fmt.Errorf("invalid xor in encoded item")
+				}
+				src = src[n:]
+				c.prev[i] ^= x
+				f.store(item, c.prev[i])
+				continue
+			}
+			encoded, n := binary.Uvarint(src)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid varint in encoded item")
+			}
+			src = src[n:]
+			var v uint64
+			switch f.encoding {
+			case DeltaEncoding:
+				v = c.prev[i] + unzigzag(encoded)
+			case DeltaOfDeltaEncoding:
+				delta := c.prevDelta[i] + unzigzag(encoded)
+				v = c.prev[i] + delta
+				c.prevDelta[i] = delta
+			}
+			c.prev[i] = v
+			f.store(item, v)
+		}
+	}
+	return dst, nil
+}
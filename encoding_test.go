@@ -0,0 +1,140 @@
+package goteafiles
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeltaEncoding(t *testing.T) {
+	ts := TimeSection{Epoch: 719162, TicksPerDay: 86400000}
+	start := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	for _, c := range []Compression{NoCompression, Gzip} {
+		configs := []TeaFileConfig{
+			WithDataType(reflect.TypeOf(Tick{})),
+			WithTimeFields(ts.Epoch, ts.TicksPerDay, []int32{0}),
+			WithDeltaEncoding(nil),
+		}
+		if c != NoCompression {
+			configs = append(configs, WithChunkCompression(c, 100))
+		}
+		tf, err := Create("test.tea", configs...)
+		if err != nil {
+			t.Fatalf("%v: error creating TeaFile: %v", c, err)
+		}
+		for i := 0; i < 1000; i++ {
+			tick := Tick{
+				Time: ts.Ticks(start.Add(time.Duration(i) * time.Second)),
+				Price: 100 + float64(i % 10) / 100,
+			}
+			err = tf.Write(tick)
+			if err != nil {
+				t.Fatalf("%v: error writing data to TeaFile: %v", c, err)
+			}
+		}
+		err = tf.Close()
+		if err != nil {
+			t.Fatalf("%v: error closing TeaFile: %v", c, err)
+		}
+		fi, err := os.Stat("test.tea")
+		if err != nil {
+			t.Fatalf("%v: error reading file size: %v", c, err)
+		}
+		if fi.Size() > 1000 * 16 * 3 / 4 {
+			t.Fatalf("%v: file is too large: %d bytes", c, fi.Size())
+		}
+
+		tf, err = OpenRead("test.tea", reflect.TypeOf(Tick{}))
+		if err != nil {
+			t.Fatalf("%v: error opening TeaFile: %v", c, err)
+		}
+		for i := 0; i < 1000; i++ {
+			val, err := tf.Read()
+			if err != nil {
+				t.Fatalf("%v: error reading item %d: %v", c, i, err)
+			}
+			tick := val.(reflect.Value).Elem().Interface().(Tick)
+			if ts.Time(tick.Time) != start.Add(time.Duration(i) * time.Second) || tick.Price != 100 + float64(i % 10) / 100 {
+				t.Fatalf("%v: got wrong item %d: %v", c, i, tick)
+			}
+		}
+		idx, err := tf.SeekTime(start.Add(321500 * time.Millisecond))
+		if err != nil || idx != 322 {
+			t.Fatalf("%v: got index %d, was expecting 322: %v", c, idx, err)
+		}
+		err = tf.Close()
+		if err != nil {
+			t.Fatalf("%v: error closing TeaFile: %v", c, err)
+		}
+	}
+
+	_, err := Create(
+		"test.tea",
+		WithDataType(reflect.TypeOf(Tick{})),
+		WithDeltaEncoding(map[string]FieldEncoding{"Volume": DeltaEncoding}))
+	if err == nil {
+		t.Fatalf("was expecting an error for an unknown field")
+	}
+	err = os.Remove("test.tea")
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+}
+
+func TestEncodingSectionCount(t *testing.T) {
+	for _, count := range []int32{-1, 3, 1 << 30} {
+		var buf bytes.Buffer
+		err := writeSection(&buf, nativeEndian, &EncodingSection{Encodings: []FieldEncoding{DeltaEncoding, XorEncoding}})
+		if err != nil {
+			t.Fatalf("error encoding section: %v", err)
+		}
+		data := buf.Bytes()
+		nativeEndian.PutUint32(data[8:], uint32(count))
+		_, err = readSection(bytes.NewReader(data), nativeEndian, 0)
+		var serr *SectionError
+		if !errors.As(err, &serr) || !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("count %d: was expecting a corrupt section, got %v", count, err)
+		}
+	}
+
+	// Items without fields cannot be decoded
+	is := &ItemSection{}
+	is.Info.ItemSize = 8
+	if _, err := newItemCodec(is, &EncodingSection{}); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("was expecting ErrCorrupt for items without fields, got %v", err)
+	}
+}
+
+func TestXorEncodingSize(t *testing.T) {
+	is, err := ItemSectionFromFormat("Price", "Price", "d")
+	if err != nil {
+		t.Fatalf("error building item section: %v", err)
+	}
+	codec, err := newItemCodec(is, &EncodingSection{Encodings: []FieldEncoding{XorEncoding}})
+	if err != nil {
+		t.Fatalf("error building codec: %v", err)
+	}
+	// A random walk of prices on a cent grid, unchanged half of the time
+	rng := rand.New(rand.NewSource(1))
+	cents := int64(10000)
+	items := make([]byte, 8 * 1000)
+	for i := 0; i < 1000; i++ {
+		if rng.Intn(2) == 0 {
+			cents += int64(rng.Intn(5) - 2)
+		}
+		nativeEndian.PutUint64(items[8 * i:], math.Float64bits(float64(cents) / 100))
+	}
+	encoded := codec.encode(nil, items)
+	if len(encoded) > len(items) / 2 {
+		t.Fatalf("encoded %d bytes of prices into %d bytes", len(items), len(encoded))
+	}
+	decoded, err := codec.decode(nil, encoded)
+	if err != nil || !bytes.Equal(decoded, items) {
+		t.Fatalf("got different items after decoding: %v", err)
+	}
+}
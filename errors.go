@@ -0,0 +1,173 @@
+package goteafiles
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrBadMagic is returned when a file does not start with the TeaFile
+	// magic value in native byte order
+	ErrBadMagic = errors.New("byteordermark mismatch")
+	// ErrEmptyFile is returned when a file has no header at all, or when
+	// items are required and the item area is empty
+	ErrEmptyFile = errors.New("no data")
+	// ErrTruncatedHeader is returned when a file ends inside its header
+	ErrTruncatedHeader = errors.New("truncated header")
+	// ErrNoItemSection is returned by item operations on a file without
+	// an item section
+	ErrNoItemSection = errors.New("no item section")
+	// ErrWrongMode is returned when reading a file opened for writing and
+	// the reverse
+	ErrWrongMode = errors.New("operation not supported in this mode")
+	// ErrNotSeekable is returned by operations requiring random access on
+	// a TeaFile backed by a forward-only reader
+	ErrNotSeekable = errors.New("underlying reader does not support seeking")
+	// ErrNotMappable is returned when memory mapping a TeaFile that is
+	// neither an os file nor held in memory
+	ErrNotMappable = errors.New("underlying reader does not support memory mapping")
+	// ErrCompressed is returned by operations requiring random access on
+	// a compressed TeaFile, which can only be read as a stream
+	ErrCompressed = errors.New("operation not supported on compressed files")
+	// ErrNoTimeField is returned by time based operations on a file
+	// without time field
+	ErrNoTimeField = errors.New("no time field")
+	// ErrTypeMismatch is returned when writing a value that does not have
+	// the data type of the file
+	ErrTypeMismatch = errors.New("value does not have the data type of the file")
+	// ErrNoDataType is returned by Read and Write on a file opened or
+	// created without a data type, whose items are read and written as
+	// bytes
+	ErrNoDataType = errors.New("no data type")
+	// ErrUnsupportedType is returned for Go types that have no field type
+	// or name value type
+	ErrUnsupportedType = errors.New("unsupported type")
+	// ErrUnknownSection is returned, wrapped in a *SectionError, for
+	// section IDs that are not known
+	ErrUnknownSection = errors.New("unknown section ID")
+	// ErrSectionSize is returned, wrapped in a *SectionError, when a
+	// section does not consume the number of bytes announced by its next
+	// section offset
+	ErrSectionSize = errors.New("section reads too few or too many bytes")
+	// ErrUnknownNameValueKind is returned for name values of an unknown
+	// kind
+	ErrUnknownNameValueKind = errors.New("unknown name value kind")
+	// ErrNoChecksumSection is returned when verifying a file written
+	// without checksums
+	ErrNoChecksumSection = errors.New("no checksum section")
+	// ErrChecksum is returned, wrapped in a *ChecksumError, when a part
+	// of a file does not match its checksum
+	ErrChecksum = errors.New("checksum mismatch")
+	// ErrCorrupt is returned when a count, size or offset read from a
+	// file is out of bounds
+	ErrCorrupt = errors.New("corrupt file")
+)
+
+// SectionError is returned when a section of the header cannot be read.
+// Offset is the position of the section ID in the file, Size the size
+// announced by the next section offset and Read the number of bytes the
+// section consumed.
+type SectionError struct {
+	ID     int32
+	Offset int64
+	Size   int64
+	Read   int64
+	Err    error
+}
+
+func (e *SectionError) Error() string {
+	return fmt.Sprintf(
+		"section %#x at offset %d (size %d, read %d): %v",
+		e.ID,
+		e.Offset,
+		e.Size,
+		e.Read,
+		e.Err)
+}
+
+func (e *SectionError) Unwrap() error {
+	return e.Err
+}
+
+// ChecksumError is returned by Verify for the first part of a file that
+// does not match its checksum. Block is the index of the item area block,
+// or -1 for the header, and Offset and Size locate it in the file.
+type ChecksumError struct {
+	Block  int64
+	Offset int64
+	Size   int64
+}
+
+func (e *ChecksumError) Error() string {
+	if e.Block < 0 {
+		return fmt.Sprintf("header: %v", ErrChecksum)
+	}
+	return fmt.Sprintf("block %d at offset %d (size %d): %v", e.Block, e.Offset, e.Size, ErrChecksum)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksum
+}
+
+// FieldTypeName returns the name of a field type as used by the TeaFiles
+// specification
+func FieldTypeName(fieldType int32) string {
+	if name, ok := fieldTypeNames[fieldType]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", fieldType)
+}
+
+// FieldMismatch describes a field that differs between a Go type and the
+// item section of a file. The data side is empty when the file has a field
+// the Go type lacks, and the file side is empty in the opposite case.
+type FieldMismatch struct {
+	Index      int
+	DataName   string
+	DataType   string
+	DataOffset int64
+	DataSize   int64
+	FileName   string
+	FileType   int32
+	FileOffset int64
+	FileSize   int64
+}
+
+func (m FieldMismatch) String() string {
+	data := "missing"
+	if m.DataName != "" {
+		data = fmt.Sprintf("%s %s at %d (%d bytes)", m.DataName, m.DataType, m.DataOffset, m.DataSize)
+	}
+	file := "missing"
+	if m.FileName != "" {
+		file = fmt.Sprintf("%s %s at %d (%d bytes)", m.FileName, FieldTypeName(m.FileType), m.FileOffset, m.FileSize)
+	}
+	return fmt.Sprintf("field %d: %s, file has %s", m.Index, data, file)
+}
+
+// SchemaMismatchError is returned when a Go type does not describe the
+// items of a file. It lists every differing field.
+type SchemaMismatchError struct {
+	DataTypeName string
+	FileTypeName string
+	DataItemSize int64
+	FileItemSize int64
+	Fields       []FieldMismatch
+}
+
+func (e *SchemaMismatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(
+		&b,
+		"data type %s (%d bytes) does not match item type %s (%d bytes)",
+		e.DataTypeName,
+		e.DataItemSize,
+		e.FileTypeName,
+		e.FileItemSize)
+	for _, field := range e.Fields {
+		b.WriteString("\n\t")
+		b.WriteString(field.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,134 @@
+package goteafiles
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	gold, err := ioutil.ReadFile("test-fixtures/acme.tea")
+	if err != nil {
+		t.Fatalf("error reading golden TeaFile: %v", err)
+	}
+	defer os.Remove("test.tea")
+
+	open := func(content []byte) error {
+		err := ioutil.WriteFile("test.tea", content, 0666)
+		if err != nil {
+			t.Fatalf("error writing TeaFile: %v", err)
+		}
+		_, err = OpenRead("test.tea", reflect.TypeOf(Data{}))
+		return err
+	}
+
+	if err := open(nil); !errors.Is(err, ErrEmptyFile) {
+		t.Fatalf("was expecting ErrEmptyFile, got %v", err)
+	}
+	if err := open(gold[:20]); !errors.Is(err, ErrTruncatedHeader) {
+		t.Fatalf("was expecting ErrTruncatedHeader, got %v", err)
+	}
+
+	badMagic := append([]byte{}, gold...)
+	badMagic[0] = 0xff
+	if err := open(badMagic); !errors.Is(err, ErrBadMagic) {
+		t.Fatalf("was expecting ErrBadMagic, got %v", err)
+	}
+
+	// Announce one byte more for the content description section
+	badSize := append([]byte{}, gold...)
+	badSize[0x8f] += 1
+	err = open(badSize)
+	var sectionErr *SectionError
+	if !errors.As(err, &sectionErr) || !errors.Is(err, ErrSectionSize) {
+		t.Fatalf("was expecting a *SectionError, got %v", err)
+	}
+	if sectionErr.ID != CONTENT_DESCRIPTION_SECTION_ID || sectionErr.Offset != 0x8b {
+		t.Fatalf("got wrong section error: %v", sectionErr)
+	}
+	if sectionErr.Size != 27 || sectionErr.Read != 26 {
+		t.Fatalf("got wrong section sizes: %v", sectionErr)
+	}
+
+	noSize := append([]byte{}, gold...)
+	nativeEndian.PutUint32(noSize[40:], 0)
+	if _, err := OpenReadBytes(noSize, nil); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("was expecting ErrCorrupt for an item size of 0, got %v", err)
+	}
+	if _, err := NewDecoder(bytes.NewReader(noSize), nil); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("was expecting ErrCorrupt decoding an item size of 0, got %v", err)
+	}
+
+	// Sizes and lengths far beyond the file are rejected before allocating
+	bigSize := append([]byte{}, gold...)
+	nativeEndian.PutUint32(bigSize[40:], 1 << 30)
+	if _, err := OpenReadBytes(bigSize, nil); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("was expecting ErrCorrupt for an item size of 1 GiB, got %v", err)
+	}
+	longName := append([]byte{}, gold...)
+	nativeEndian.PutUint32(longName[44:], 1 << 30)
+	if _, err := OpenReadBytes(longName, nil); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("was expecting ErrCorrupt for a type name of 1 GiB, got %v", err)
+	}
+
+	tf, err := Create("test.tea", WithDataType(reflect.TypeOf(Data{})))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	if _, err := tf.Read(); !errors.Is(err, ErrWrongMode) {
+		t.Fatalf("was expecting ErrWrongMode, got %v", err)
+	}
+	if err := tf.Write(uint64(1)); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("was expecting ErrTypeMismatch, got %v", err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	tf, err = OpenRead("test.tea", reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	if _, err := tf.OpenReadableMapping(); !errors.Is(err, ErrEmptyFile) {
+		t.Fatalf("was expecting ErrEmptyFile, got %v", err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	tf, err = OpenRead("test-fixtures/acme.tea", nil)
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	if _, err := tf.Read(); !errors.Is(err, ErrNoDataType) {
+		t.Fatalf("was expecting ErrNoDataType, got %v", err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	tf, err = CreateBuffer(WithSchema(Schema{TypeName: "Tick", Fields: []SchemaField{{Name: "Time", Type: FIELD_TYPE_INT64}}}))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	if err := tf.Write(int64(1)); !errors.Is(err, ErrNoDataType) {
+		t.Fatalf("was expecting ErrNoDataType, got %v", err)
+	}
+
+	tf, err = Create("test.tea", WithContentDescription("no items"))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	if _, err := OpenRead("test.tea", reflect.TypeOf(Data{})); !errors.Is(err, ErrNoItemSection) {
+		t.Fatalf("was expecting ErrNoItemSection, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package goteafiles
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// flatField is a leaf field of a Go type, with its path from the root
+// type and its offset in it
+type flatField struct {
+	Name   string
+	Offset uintptr
+	Type   reflect.Type
+}
+
+// flattenType lists the leaf fields of a struct type. Nested structs are
+// flattened into dotted names (Bid.Price) and fixed-size arrays into
+// indexed names (Levels[3]), so that they can be described by an item
+// section. Fields named _ are padding and are skipped.
+func flattenType(typ reflect.Type) ([]flatField, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("data type %s is not a struct", typ)
+	}
+	return flattenStruct(typ, "", 0, nil)
+}
+
+func flattenStruct(typ reflect.Type, prefix string, offset uintptr, fields []flatField) ([]flatField, error) {
+	var err error
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Name == "_" {
+			continue
+		}
+		fields, err = flattenValue(field.Type, prefix + field.Name, offset + field.Offset, fields)
+		if err != nil { return nil, err }
+	}
+	return fields, nil
+}
+
+func flattenValue(typ reflect.Type, name string, offset uintptr, fields []flatField) ([]flatField, error) {
+	switch {
+	case typ == decimalType:
+		return append(fields, flatField{Name: name, Offset: offset, Type: typ}), nil
+
+	case typ.Kind() == reflect.Struct:
+		return flattenStruct(typ, name + ".", offset, fields)
+
+	case typ.Kind() == reflect.Array:
+		var err error
+		for i := 0; i < typ.Len(); i++ {
+			elemName := fmt.Sprintf("%s[%d]", name, i)
+			elemOffset := offset + uintptr(i) * typ.Elem().Size()
+			fields, err = flattenValue(typ.Elem(), elemName, elemOffset, fields)
+			if err != nil { return nil, err }
+		}
+		return fields, nil
+
+	default:
+		if _, err := fieldTypeOf(typ); err != nil {
+			return nil, fmt.Errorf("field %s: %v", name, err)
+		}
+		return append(fields, flatField{Name: name, Offset: offset, Type: typ}), nil
+	}
+}
+
+// diffSchema compares the leaf fields of a Go type with the item section
+// of a file. Fields are compared by position, name, type and offset, and
+// item sizes must be equal. When byName is set, as for explicit layouts,
+// fields are matched by name and offsets and item sizes may differ as
+// long as every field fits in the file item.
+func diffSchema(dataType reflect.Type, fields []flatField, is *ItemSection, byName bool) error {
+	e := &SchemaMismatchError{
+		DataTypeName: dataType.String(),
+		FileTypeName: is.Info.ItemTypeName,
+		DataItemSize: int64(dataType.Size()),
+		FileItemSize: int64(is.Info.ItemSize),
+	}
+	mismatch := func(i int, dataField *flatField, fileField *ItemSectionField) FieldMismatch {
+		m := FieldMismatch{Index: i}
+		if dataField != nil {
+			m.DataName = dataField.Name
+			m.DataType = dataField.Type.String()
+			m.DataOffset = int64(dataField.Offset)
+			m.DataSize = int64(dataField.Type.Size())
+		}
+		if fileField != nil {
+			m.FileName = fileField.Name
+			m.FileType = fileField.Type
+			m.FileOffset = int64(fileField.Offset)
+			m.FileSize = int64(fieldTypeSizes[fileField.Type])
+		}
+		return m
+	}
+
+	if byName {
+		dataIndex := make(map[string]int, len(fields))
+		for i, field := range fields {
+			dataIndex[field.Name] = i
+		}
+		matched := make(map[string]bool, len(is.Fields))
+		for i := range is.Fields {
+			fileField := &is.Fields[i]
+			j, ok := dataIndex[fileField.Name]
+			if !ok {
+				e.Fields = append(e.Fields, mismatch(i, nil, fileField))
+				continue
+			}
+			matched[fileField.Name] = true
+			dataField := &fields[j]
+			end := int64(fileField.Offset) + int64(dataField.Type.Size())
+			if !fieldTypeMatches(fileField.Type, dataField.Type) || fileField.Offset < 0 || end > e.FileItemSize {
+				e.Fields = append(e.Fields, mismatch(i, dataField, fileField))
+			}
+		}
+		for i := range fields {
+			if !matched[fields[i].Name] {
+				e.Fields = append(e.Fields, mismatch(i, &fields[i], nil))
+			}
+		}
+		if len(e.Fields) == 0 {
+			return nil
+		}
+		return e
+	}
+
+	n := len(fields)
+	if len(is.Fields) > n {
+		n = len(is.Fields)
+	}
+	for i := 0; i < n; i++ {
+		var dataField *flatField
+		var fileField *ItemSectionField
+		if i < len(fields) {
+			dataField = &fields[i]
+		}
+		if i < len(is.Fields) {
+			fileField = &is.Fields[i]
+		}
+		if dataField == nil || fileField == nil ||
+			dataField.Name != fileField.Name ||
+			!fieldTypeMatches(fileField.Type, dataField.Type) ||
+			dataField.Offset != uintptr(fileField.Offset) {
+			e.Fields = append(e.Fields, mismatch(i, dataField, fileField))
+		}
+	}
+	if len(e.Fields) == 0 && e.DataItemSize == e.FileItemSize {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,180 @@
+package goteafiles
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// formatFieldTypes maps the characters of Python struct formats to field
+// types, as used by the Python TeaFiles library
+var formatFieldTypes = map[byte]int32{
+	'b': FIELD_TYPE_INT8,
+	'h': FIELD_TYPE_INT16,
+	'i': FIELD_TYPE_INT32,
+	'q': FIELD_TYPE_INT64,
+	'B': FIELD_TYPE_UINT8,
+	'H': FIELD_TYPE_UINT16,
+	'I': FIELD_TYPE_UINT32,
+	'Q': FIELD_TYPE_UINT64,
+	'f': FIELD_TYPE_FLOAT,
+	'd': FIELD_TYPE_DOUBLE,
+}
+
+// ItemSectionFromFormat returns the item section described by a Python
+// struct format, such as "QBQBQ", and the space separated names of its
+// fields, as the Python TeaFiles library creates it. Without prefix, or
+// with "@", fields are aligned on their size but the item is not padded
+// at its end. With "=", or the "<" or ">" prefix of the native byte
+// order, fields are packed. Repeat counts and "x" pad bytes are supported.
+func ItemSectionFromFormat(typeName string, fieldNames string, format string) (*ItemSection, error) {
+	aligned := true
+	if len(format) > 0 {
+		switch format[0] {
+		case '@':
+			format = format[1:]
+		case '=':
+			aligned = false
+			format = format[1:]
+		case '<', '>', '!':
+			if (format[0] == '<') != (nativeEndian == binary.LittleEndian) {
+				return nil, fmt.Errorf("format %q is not in native byte order", format)
+			}
+			aligned = false
+			format = format[1:]
+		}
+	}
+	names := strings.Fields(fieldNames)
+	is := &ItemSection{}
+	is.Info.ItemTypeName = typeName
+	var offset int32
+	for i := 0; i < len(format); i++ {
+		count := 1
+		j := i
+		for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+			j++
+		}
+		if j > i {
+			var err error
+			count, err = strconv.Atoi(format[i:j])
+			if err != nil { return nil, fmt.Errorf("format %q: %v", format, err) }
+			if j == len(format) {
+				return nil, fmt.Errorf("format %q ends with a count", format)
+			}
+			i = j
+		}
+		c := format[i]
+		if c == ' ' {
+			continue
+		}
+		if c == 'x' {
+			offset += int32(count)
+			continue
+		}
+		fieldType, ok := formatFieldTypes[c]
+		if !ok {
+			return nil, fmt.Errorf("format %q: %w: %q", format, ErrUnsupportedType, c)
+		}
+		size := fieldTypeSizes[fieldType]
+		for k := 0; k < count; k++ {
+			if aligned && offset % size != 0 {
+				offset += size - offset % size
+			}
+			index := len(is.Fields)
+			if index >= len(names) {
+				return nil, fmt.Errorf("format %q has more fields than the %d field names", format, len(names))
+			}
+			is.Fields = append(is.Fields, ItemSectionField{
+				Index: int32(index),
+				Type: fieldType,
+				Offset: offset,
+				Name: names[index],
+			})
+			offset += size
+		}
+	}
+	if len(is.Fields) != len(names) {
+		return nil, fmt.Errorf("format %q has %d fields for %d field names", format, len(is.Fields), len(names))
+	}
+	is.Info.FieldCount = int32(len(is.Fields))
+	is.Info.ItemSize = offset
+	return is, nil
+}
+
+// Format returns the Python struct format and the space separated field
+// names of the item section, as accepted by ItemSectionFromFormat. Gaps
+// between fields are written as pad bytes, and a packed format is
+// returned when the fields are not aligned.
+func (is *ItemSection) Format() (string, string, error) {
+	fields := append([]ItemSectionField{}, is.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset })
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	format, err := formatOf(fields, is.Info.ItemSize, true)
+	if err != nil {
+		format, err = formatOf(fields, is.Info.ItemSize, false)
+	}
+	return format, strings.Join(names, " "), err
+}
+
+// formatOf writes the format of fields sorted by offset
+func formatOf(fields []ItemSectionField, itemSize int32, aligned bool) (string, error) {
+	var b strings.Builder
+	if !aligned {
+		b.WriteString("=")
+	}
+	pad := func(n int32) {
+		if n == 1 {
+			b.WriteString("x")
+		} else if n > 1 {
+			b.WriteString(strconv.Itoa(int(n)) + "x")
+		}
+	}
+	var offset int32
+	for _, f := range fields {
+		var c byte
+		for char, fieldType := range formatFieldTypes {
+			if fieldType == f.Type {
+				c = char
+			}
+		}
+		if c == 0 {
+			return "", fmt.Errorf("field %s: %w: %s has no format character", f.Name, ErrUnsupportedType, FieldTypeName(f.Type))
+		}
+		size := fieldTypeSizes[f.Type]
+		if f.Offset < offset || (aligned && f.Offset % size != 0) {
+			return "", fmt.Errorf("field %s at offset %d overlaps or is misaligned", f.Name, f.Offset)
+		}
+		next := offset
+		if aligned && next % size != 0 {
+			next += size - next % size
+		}
+		if f.Offset != next {
+			pad(f.Offset - offset)
+		}
+		b.WriteByte(c)
+		offset = f.Offset + size
+	}
+	if itemSize < offset {
+		return "", fmt.Errorf("item size %d smaller than its fields", itemSize)
+	}
+	pad(itemSize - offset)
+	return b.String(), nil
+}
+
+// WithFormat describes the items with a Python struct format and the
+// space separated names of their fields, as the Python TeaFiles library
+// does. Items are then written with WriteBytes and read with ReadBytes.
+func WithFormat(typeName string, fieldNames string, format string) TeaFileConfig {
+	return configFunc(func (tf *TeaFile) error {
+		is, err := ItemSectionFromFormat(typeName, fieldNames, format)
+		if err != nil { return err }
+		tf.dataType = nil
+		tf.itemSection = is
+		return nil
+	})
+}
@@ -0,0 +1,77 @@
+package goteafiles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tf, err := OpenRead("test-fixtures/acme.tea", nil)
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	defer tf.Close()
+
+	// Same item section as the Python library
+	is, err := ItemSectionFromFormat("Data", "Time Price Volume Prob Prib", "QBQBQ")
+	if err != nil {
+		t.Fatalf("error parsing format: %v", err)
+	}
+	if !reflect.DeepEqual(is, tf.ItemSection()) {
+		t.Fatalf("got item section %+v, was expecting %+v", is, tf.ItemSection())
+	}
+	format, names, err := tf.ItemSection().Format()
+	if err != nil || format != "QBQBQ" || names != "Time Price Volume Prob Prib" {
+		t.Fatalf("got format %q and names %q: %v", format, names, err)
+	}
+
+	formats := []struct {
+		format  string
+		offsets []int32
+		size    int32
+		printed string
+	}{
+		// No padding at the end, as Python's struct.calcsize
+		{"QB", []int32{0, 8}, 9, "QB"},
+		{"@BQ", []int32{0, 8}, 16, "BQ"},
+		{"=BQ", []int32{0, 1}, 9, "=BQ"},
+		{"<BdH", []int32{0, 1, 9}, 11, "=BdH"},
+		{"2BxI", []int32{0, 1, 4}, 8, "BBI"},
+		{"B3xh2x", []int32{0, 4}, 8, "B3xh2x"},
+	}
+	for _, f := range formats {
+		var names string
+		for i := range f.offsets {
+			names += string(rune('a' + i)) + " "
+		}
+		is, err := ItemSectionFromFormat("T", names, f.format)
+		if err != nil {
+			t.Fatalf("error parsing format %q: %v", f.format, err)
+		}
+		var offsets []int32
+		for _, field := range is.Fields {
+			offsets = append(offsets, field.Offset)
+		}
+		if !reflect.DeepEqual(offsets, f.offsets) || is.Info.ItemSize != f.size {
+			t.Fatalf("%q: got offsets %v and size %d", f.format, offsets, is.Info.ItemSize)
+		}
+		printed, _, err := is.Format()
+		if err != nil || printed != f.printed {
+			t.Fatalf("%q: got format %q, was expecting %q: %v", f.format, printed, f.printed, err)
+		}
+	}
+
+	for _, format := range []string{"QZ", "QB", "Q2", ">Q"} {
+		if _, err := ItemSectionFromFormat("T", "a b c", format); err == nil {
+			t.Fatalf("was expecting an error for format %q", format)
+		}
+	}
+
+	tf, err = CreateBuffer(WithFormat("Data", "Time Price", "qd"), WithTimeFields(719162, 86400000, []int32{0}))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	if tf.ItemSection().Info.ItemSize != 16 {
+		t.Fatalf("got wrong item size %d", tf.ItemSection().Info.ItemSize)
+	}
+}
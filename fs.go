@@ -0,0 +1,57 @@
+package goteafiles
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+)
+
+// OpenReadFS opens a TeaFile for reading from a file system, such as an
+// embed.FS, os.DirFS or a zip archive. Files backed by an os file can be
+// memory mapped. Files supporting Seek, such as embedded files, are read
+// item by item, and other files are read into memory. Compressed files
+// are detected as OpenRead does, and read sequentially.
+func OpenReadFS(fsys fs.FS, name string, dataType reflect.Type, configs ...TeaFileConfig) (*TeaFile, error) {
+	f, err := fsys.Open(name)
+	if err != nil { return nil, err }
+
+	switch file := f.(type) {
+	case *os.File:
+		return openReadFile(file, name, dataType, configs)
+
+	case io.ReadSeeker:
+		c, err := sniffSeeker(file)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if c != NoCompression {
+			return openReadCompressed(file, f, name, c, dataType, configs)
+		}
+		tf := &TeaFile{
+			mode: os.O_RDONLY,
+			fileName: name,
+			reader: file,
+			seeker: file,
+			closer: f,
+			size: seekerSize(file),
+			dataType: dataType,
+		}
+		err = tf.open(configs)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return tf, nil
+
+	default:
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil { return nil, err }
+		tf, err := OpenReadBytes(data, dataType, configs...)
+		if err != nil { return nil, err }
+		tf.fileName = name
+		return tf, nil
+	}
+}
@@ -0,0 +1,144 @@
+package goteafiles
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOpenReadFS(t *testing.T) {
+	gold, err := ioutil.ReadFile("test-fixtures/acme.tea")
+	if err != nil {
+		t.Fatalf("error reading golden TeaFile: %v", err)
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, err := zw.Create("data/acme.tea")
+	if err != nil {
+		t.Fatalf("error creating zip entry: %v", err)
+	}
+	_, err = w.Write(gold)
+	if err != nil {
+		t.Fatalf("error writing zip entry: %v", err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatalf("error closing zip: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("error opening zip: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		fsys     fs.FS
+		path     string
+		mappable bool
+	}{
+		{"dir", os.DirFS("test-fixtures"), "acme.tea", true},
+		{"map", fstest.MapFS{"acme.tea": {Data: gold}}, "acme.tea", false},
+		{"zip", zr, "data/acme.tea", true},
+	}
+	for _, test := range tests {
+		tf, err := OpenReadFS(test.fsys, test.path, reflect.TypeOf(Data{}))
+		if err != nil {
+			t.Fatalf("%s: error opening TeaFile: %v", test.name, err)
+		}
+		n, err := tf.ItemCount()
+		if err != nil || n != 2 {
+			t.Fatalf("%s: got %d items, was expecting 2: %v", test.name, n, err)
+		}
+		val, err := tf.Read()
+		if err != nil {
+			t.Fatalf("%s: error reading data: %v", test.name, err)
+		}
+		if item := val.(reflect.Value).Elem().Interface().(Data); item != data {
+			t.Fatalf("%s: got %v, was expecting %v", test.name, item, data)
+		}
+		_, err = tf.OpenReadableMapping()
+		if test.mappable && err != nil {
+			t.Fatalf("%s: error mapping TeaFile: %v", test.name, err)
+		}
+		if !test.mappable && err != ErrNotMappable {
+			t.Fatalf("%s: was expecting ErrNotMappable, got %v", test.name, err)
+		}
+		err = tf.Close()
+		if err != nil {
+			t.Fatalf("%s: error closing TeaFile: %v", test.name, err)
+		}
+	}
+}
+
+func TestOpenReadFSCompressed(t *testing.T) {
+	gold, err := ioutil.ReadFile("test-fixtures/acme.tea")
+	if err != nil {
+		t.Fatalf("error reading golden TeaFile: %v", err)
+	}
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, err = gw.Write(gold)
+	if err != nil {
+		t.Fatalf("error compressing TeaFile: %v", err)
+	}
+	err = gw.Close()
+	if err != nil {
+		t.Fatalf("error compressing TeaFile: %v", err)
+	}
+
+	// Seekable embedded files, files read in memory and bytes
+	open := map[string]func() (*TeaFile, error){
+		"map": func() (*TeaFile, error) {
+			fsys := fstest.MapFS{"acme.tea.gz": {Data: compressed.Bytes()}}
+			return OpenReadFS(fsys, "acme.tea.gz", reflect.TypeOf(Data{}))
+		},
+		"memory": func() (*TeaFile, error) {
+			fsys := readOnlyFS{fstest.MapFS{"acme.tea.gz": {Data: compressed.Bytes()}}}
+			return OpenReadFS(fsys, "acme.tea.gz", reflect.TypeOf(Data{}))
+		},
+		"bytes": func() (*TeaFile, error) {
+			return OpenReadBytes(compressed.Bytes(), reflect.TypeOf(Data{}))
+		},
+	}
+	for name, open := range open {
+		tf, err := open()
+		if err != nil {
+			t.Fatalf("%s: error opening TeaFile: %v", name, err)
+		}
+		for i := 0; i < 2; i++ {
+			val, err := tf.Read()
+			if err != nil {
+				t.Fatalf("%s: error reading data: %v", name, err)
+			}
+			if item := val.(reflect.Value).Elem().Interface().(Data); item != data {
+				t.Fatalf("%s: got %v, was expecting %v", name, item, data)
+			}
+		}
+		if _, err := tf.OpenReadableMapping(); !errors.Is(err, ErrCompressed) {
+			t.Fatalf("%s: was expecting ErrCompressed, got %v", name, err)
+		}
+		err = tf.Close()
+		if err != nil {
+			t.Fatalf("%s: error closing TeaFile: %v", name, err)
+		}
+	}
+}
+
+// readOnlyFS hides the Seek method of the files of an fs.FS
+type readOnlyFS struct {
+	fsys fs.FS
+}
+
+func (r readOnlyFS) Open(name string) (fs.File, error) {
+	f, err := r.fsys.Open(name)
+	if err != nil { return nil, err }
+	return struct{ fs.File }{f}, nil
+}
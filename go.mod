@@ -1,9 +1,10 @@
 module github.com/melaurent/goteafiles
 
-go 1.12
+go 1.16
 
 require (
 	github.com/edsrzf/mmap-go v1.0.0
+	github.com/klauspost/compress v1.15.15
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/satori/go.uuid v1.2.0
 	golang.org/x/exp v0.0.0-20190510132918-efd6b22b2522
@@ -0,0 +1,318 @@
+package goteafiles
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportFormat is the format of the rows read by Import
+type ImportFormat int
+
+const (
+	// CSV rows start with a header row naming the columns
+	CSV ImportFormat = iota
+	// JSONLines rows are JSON objects, one per line
+	JSONLines
+)
+
+// ImportOptions describe the rows read by Import
+type ImportOptions struct {
+	Format ImportFormat
+	// Comma separates the columns of CSV rows, ',' if zero
+	Comma rune
+	// TimeFormat is the layout of time fields, as used by time.Parse, or
+	// one of "unix", "unixms", "unixus" and "unixns" for numbers of
+	// seconds, milliseconds, microseconds and nanoseconds since 1970, or
+	// "ticks" for numbers of ticks of the time section. RFC 3339 if empty.
+	TimeFormat string
+}
+
+// ImportError reports a row that cannot be imported. Line is the line of
+// the row in the input, starting at 1, and Column the name of the column
+// at fault, if any.
+type ImportError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *ImportError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: column %s: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// Import writes the rows read from r as items of tf, which is usually
+// created with WithSchema. Columns are matched to fields by name, every
+// field requiring a column, and values are checked against the field
+// types. Time fields are parsed with the time format. It returns the
+// number of items written, which stops at the first invalid row.
+func Import(tf *TeaFile, r io.Reader, opts ImportOptions) (int64, error) {
+	if tf.itemSection == nil {
+		return 0, ErrNoItemSection
+	}
+	// Times are stored as 64-bit ticks
+	for _, f := range tf.itemSection.Fields {
+		if tf.timeSection.IsTimeField(f) && f.Type != FIELD_TYPE_INT64 && f.Type != FIELD_TYPE_UINT64 {
+			return 0, fmt.Errorf("time field %s is a %s, not a 64-bit integer", f.Name, FieldTypeName(f.Type))
+		}
+	}
+	im := &importer{
+		tf: tf,
+		opts: opts,
+		item: make([]byte, tf.itemSection.Info.ItemSize),
+	}
+	if opts.Format == JSONLines {
+		return im.importJSONLines(r)
+	}
+	if opts.Format != CSV {
+		return 0, fmt.Errorf("unknown import format %d", opts.Format)
+	}
+	return im.importCSV(r)
+}
+
+type importer struct {
+	tf    *TeaFile
+	opts  ImportOptions
+	item  []byte
+	count int64
+}
+
+func (im *importer) importCSV(r io.Reader) (int64, error) {
+	records := newCSVRecords(r, im.opts.Comma)
+	header, line, err := records.next()
+	if err == io.EOF {
+		return 0, &ImportError{Line: 1, Err: fmt.Errorf("no header row")}
+	}
+	if err != nil { return 0, &ImportError{Line: line, Err: err} }
+	columns := make([]int, len(im.tf.itemSection.Fields))
+	for i, f := range im.tf.itemSection.Fields {
+		columns[i] = -1
+		for j, name := range header {
+			if strings.TrimSpace(name) == f.Name {
+				columns[i] = j
+			}
+		}
+		if columns[i] < 0 {
+			return 0, &ImportError{Line: line, Column: f.Name, Err: fmt.Errorf("missing column")}
+		}
+	}
+
+	for {
+		record, line, err := records.next()
+		if err == io.EOF {
+			return im.count, nil
+		}
+		if err != nil { return im.count, &ImportError{Line: line, Err: err} }
+		if len(record) != len(header) {
+			return im.count, &ImportError{Line: line, Err: fmt.Errorf("%d columns, header has %d", len(record), len(header))}
+		}
+		for i, f := range im.tf.itemSection.Fields {
+			err = im.setField(f, strings.TrimSpace(record[columns[i]]))
+			if err != nil { return im.count, &ImportError{Line: line, Column: f.Name, Err: err} }
+		}
+		err = im.write(line)
+		if err != nil { return im.count, err }
+	}
+}
+
+func (im *importer) importJSONLines(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024 * 1024)
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		d := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		d.UseNumber()
+		var row map[string]interface{}
+		err := d.Decode(&row)
+		if err != nil { return im.count, &ImportError{Line: line, Err: err} }
+		for _, f := range im.tf.itemSection.Fields {
+			value, ok := row[f.Name]
+			if !ok {
+				return im.count, &ImportError{Line: line, Column: f.Name, Err: fmt.Errorf("missing value")}
+			}
+			var s string
+			switch v := value.(type) {
+			case json.Number:
+				s = v.String()
+			case string:
+				s = v
+			default:
+				return im.count, &ImportError{Line: line, Column: f.Name, Err: fmt.Errorf("%w: %T", ErrUnsupportedType, value)}
+			}
+			err = im.setField(f, s)
+			if err != nil { return im.count, &ImportError{Line: line, Column: f.Name, Err: err} }
+		}
+		err = im.write(line)
+		if err != nil { return im.count, err }
+	}
+	return im.count, scanner.Err()
+}
+
+func (im *importer) write(line int) error {
+	err := im.tf.WriteBytes(im.item)
+	if err != nil { return &ImportError{Line: line, Err: err} }
+	im.count += 1
+	return nil
+}
+
+// setField parses a value and stores it in the item
+func (im *importer) setField(f ItemSectionField, s string) error {
+	b := im.item[f.Offset:]
+	if im.tf.timeSection.IsTimeField(f) {
+		ticks, err := im.parseTime(s)
+		if err != nil { return err }
+		nativeEndian.PutUint64(b, uint64(ticks))
+		return nil
+	}
+	switch f.Type {
+	case FIELD_TYPE_INT8, FIELD_TYPE_INT16, FIELD_TYPE_INT32, FIELD_TYPE_INT64:
+		v, err := strconv.ParseInt(s, 10, int(fieldTypeSizes[f.Type]) * 8)
+		if err != nil { return err }
+		putUint(b, fieldTypeSizes[f.Type], uint64(v))
+	case FIELD_TYPE_UINT8, FIELD_TYPE_UINT16, FIELD_TYPE_UINT32, FIELD_TYPE_UINT64:
+		v, err := strconv.ParseUint(s, 10, int(fieldTypeSizes[f.Type]) * 8)
+		if err != nil { return err }
+		putUint(b, fieldTypeSizes[f.Type], v)
+	case FIELD_TYPE_FLOAT:
+		v, err := strconv.ParseFloat(s, 32)
+		if err != nil { return err }
+		nativeEndian.PutUint32(b, math.Float32bits(float32(v)))
+	case FIELD_TYPE_DOUBLE:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil { return err }
+		nativeEndian.PutUint64(b, math.Float64bits(v))
+	case FIELD_TYPE_NET_DECIMAL:
+		v, err := ParseDecimal(s)
+		if err != nil { return err }
+		nativeEndian.PutUint32(b, v.Flags)
+		nativeEndian.PutUint32(b[4:], v.Hi)
+		nativeEndian.PutUint32(b[8:], v.Lo)
+		nativeEndian.PutUint32(b[12:], v.Mid)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, FieldTypeName(f.Type))
+	}
+	return nil
+}
+
+func putUint(b []byte, size int32, v uint64) {
+	switch size {
+	case 1:
+		b[0] = uint8(v)
+	case 2:
+		nativeEndian.PutUint16(b, uint16(v))
+	case 4:
+		nativeEndian.PutUint32(b, uint32(v))
+	default:
+		nativeEndian.PutUint64(b, v)
+	}
+}
+
+var unixUnits = map[string]time.Duration{
+	"unix"  : time.Second,
+	"unixms": time.Millisecond,
+	"unixus": time.Microsecond,
+	"unixns": time.Nanosecond,
+}
+
+// parseTime returns the ticks of a time value
+func (im *importer) parseTime(s string) (int64, error) {
+	format := im.opts.TimeFormat
+	if format == "ticks" {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	var t time.Time
+	if unit, ok := unixUnits[format]; ok {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil { return 0, err }
+		perSecond := int64(time.Second / unit)
+		t = time.Unix(v / perSecond, v % perSecond * int64(unit))
+	} else {
+		if format == "" {
+			format = time.RFC3339Nano
+		}
+		var err error
+		t, err = time.Parse(format, s)
+		if err != nil { return 0, err }
+	}
+	return im.tf.timeSection.Ticks(t), nil
+}
+
+// csvRecords reads CSV records, keeping track of the line each record
+// starts at. Quoted values may span several lines.
+type csvRecords struct {
+	reader *csv.Reader
+	lines  *lineReader
+}
+
+func newCSVRecords(r io.Reader, comma rune) *csvRecords {
+	lines := &lineReader{r: bufio.NewReader(r)}
+	reader := csv.NewReader(lines)
+	if comma != 0 {
+		reader.Comma = comma
+	}
+	reader.FieldsPerRecord = -1
+	return &csvRecords{reader: reader, lines: lines}
+}
+
+// next returns the next record that is not blank and its line
+func (cr *csvRecords) next() ([]string, int, error) {
+	for {
+		record, err := cr.reader.Read()
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return nil, perr.StartLine, perr.Err
+		}
+		if err != nil { return nil, cr.lines.line, err }
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		// The record ends on the last line read, less the line breaks
+		// of its quoted values
+		breaks := 0
+		for _, value := range record {
+			breaks += strings.Count(value, "\n")
+		}
+		return record, cr.lines.line - breaks, nil
+	}
+}
+
+// lineReader returns at most a line per Read, so that the lines it read
+// are those of the records read from it
+type lineReader struct {
+	r    *bufio.Reader
+	rest []byte
+	line int
+}
+
+func (lr *lineReader) Read(p []byte) (int, error) {
+	if len(lr.rest) == 0 {
+		var err error
+		lr.rest, err = lr.r.ReadBytes('\n')
+		if len(lr.rest) == 0 {
+			return 0, err
+		}
+		lr.line += 1
+	}
+	n := copy(p, lr.rest)
+	lr.rest = lr.rest[n:]
+	return n, nil
+}
@@ -0,0 +1,101 @@
+package goteafiles
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var importSchema = Schema{
+	TypeName: "Tick",
+	Fields: []SchemaField{
+		{Name: "Time", Type: FIELD_TYPE_INT64},
+		{Name: "Price", Type: FIELD_TYPE_DOUBLE},
+	},
+}
+
+func TestImport(t *testing.T) {
+	inputs := []struct {
+		opts  ImportOptions
+		input string
+	}{
+		{ImportOptions{}, "Price,Time\n1.5,2020-01-02T09:00:00Z\n\n\"2\",2020-01-02T09:00:01Z\n"},
+		{ImportOptions{Comma: '\t', TimeFormat: "unixms"}, "Time\tPrice\n1577955600000\t1.5\n1577955601000\t2\n"},
+		{ImportOptions{Format: JSONLines, TimeFormat: "unix"}, `{"Time": 1577955600, "Price": 1.5}` + "\n" + `{"Time": "1577955601", "Price": 2}` + "\n"},
+	}
+	for _, in := range inputs {
+		tf, err := CreateBuffer(WithSchema(importSchema), WithTimeFields(719162, 86400000, []int32{0}))
+		if err != nil {
+			t.Fatalf("error creating TeaFile: %v", err)
+		}
+		n, err := Import(tf, strings.NewReader(in.input), in.opts)
+		if err != nil || n != 2 {
+			t.Fatalf("imported %d items, was expecting 2: %v", n, err)
+		}
+		tf, err = OpenReadBytes(tf.Bytes(), nil)
+		if err != nil {
+			t.Fatalf("error opening TeaFile: %v", err)
+		}
+		item := make([]byte, 16)
+		for i := 0; i < 2; i++ {
+			err = tf.ReadBytes(item)
+			if err != nil {
+				t.Fatalf("error reading item %d: %v", i, err)
+			}
+			ti, err := tf.ItemTime(item)
+			if err != nil || !ti.Equal(time.Date(2020, 1, 2, 9, 0, i, 0, time.UTC)) {
+				t.Fatalf("got wrong time for item %d: %v %v", i, ti, err)
+			}
+			price, _ := tf.ItemSection().Fields[1].Value(item)
+			if price != 1.5 + float64(i) / 2 {
+				t.Fatalf("got wrong price for item %d: %v", i, price)
+			}
+		}
+	}
+
+	errs := []struct {
+		opts   ImportOptions
+		input  string
+		line   int
+		column string
+	}{
+		{ImportOptions{}, "Time\n2020-01-02T09:00:00Z\n", 1, "Price"},
+		{ImportOptions{}, "Time,Price\n2020-01-02T09:00:00Z,1\n\n2020-01-02,2\n", 4, "Time"},
+		{ImportOptions{}, "Time,Price\n2020-01-02T09:00:00Z,\"1\n\"\n2020-01-02T09:00:00Z,x\n", 4, "Price"},
+		{ImportOptions{}, "Time,Price\r\n2020-01-02T09:00:00Z,1\r\n  \r\n2020-01-02T09:00:00Z,x\r\n", 4, "Price"},
+		{ImportOptions{}, "Time,Price\n2020-01-02T09:00:00Z,1\n2020-01-02T09:00:00Z,\"1\n", 3, ""},
+		{ImportOptions{Format: JSONLines}, `{"Time": "2020-01-02T09:00:00Z"}`, 1, "Price"},
+	}
+	for _, e := range errs {
+		tf, err := CreateBuffer(WithSchema(importSchema), WithTimeFields(719162, 86400000, []int32{0}))
+		if err != nil {
+			t.Fatalf("error creating TeaFile: %v", err)
+		}
+		_, err = Import(tf, strings.NewReader(e.input), e.opts)
+		var ierr *ImportError
+		if !errors.As(err, &ierr) || ierr.Line != e.line || ierr.Column != e.column {
+			t.Fatalf("was expecting an error on line %d, column %s, got %v", e.line, e.column, err)
+		}
+	}
+
+	// Integer fields are checked against their size
+	tf, err := CreateBuffer(WithSchema(Schema{Fields: []SchemaField{{Name: "Size", Type: FIELD_TYPE_INT8}}}))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	if _, err = Import(tf, strings.NewReader("Size\n127\n128\n"), ImportOptions{}); err == nil {
+		t.Fatalf("was expecting an error for an overflow")
+	}
+
+	// Times are only stored in 64-bit integers
+	tf, err = CreateBuffer(
+		WithSchema(Schema{Fields: []SchemaField{{Name: "Time", Type: FIELD_TYPE_INT32}, {Name: "Size", Type: FIELD_TYPE_INT8}}}),
+		WithTimeFields(719162, 86400, []int32{0}))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	if _, err = Import(tf, strings.NewReader("Time,Size\n2020-01-02T09:00:00Z,1\n"), ImportOptions{}); err == nil {
+		t.Fatalf("was expecting an error for an Int32 time field")
+	}
+}
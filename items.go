@@ -0,0 +1,77 @@
+package goteafiles
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Value decodes the field from the bytes of an item, in the layout of
+// the item section. It returns an int8, int16, int32, int64, uint8,
+// uint16, uint32, uint64, float32, float64 or Decimal depending on the
+// field type.
+func (f ItemSectionField) Value(item []byte) (interface{}, error) {
+	size, ok := fieldTypeSizes[f.Type]
+	if !ok {
+		return nil, fmt.Errorf("field %s: %w: %s", f.Name, ErrUnsupportedType, FieldTypeName(f.Type))
+	}
+	if f.Offset < 0 || int(f.Offset) + int(size) > len(item) {
+		return nil, fmt.Errorf("field %s at offset %d outside item of %d bytes", f.Name, f.Offset, len(item))
+	}
+	b := item[f.Offset:]
+	switch f.Type {
+	case FIELD_TYPE_INT8:
+		return int8(b[0]), nil
+	case FIELD_TYPE_INT16:
+		return int16(nativeEndian.Uint16(b)), nil
+	case FIELD_TYPE_INT32:
+		return int32(nativeEndian.Uint32(b)), nil
+	case FIELD_TYPE_INT64:
+		return int64(nativeEndian.Uint64(b)), nil
+	case FIELD_TYPE_UINT8:
+		return b[0], nil
+	case FIELD_TYPE_UINT16:
+		return nativeEndian.Uint16(b), nil
+	case FIELD_TYPE_UINT32:
+		return nativeEndian.Uint32(b), nil
+	case FIELD_TYPE_UINT64:
+		return nativeEndian.Uint64(b), nil
+	case FIELD_TYPE_FLOAT:
+		return math.Float32frombits(nativeEndian.Uint32(b)), nil
+	case FIELD_TYPE_DOUBLE:
+		return math.Float64frombits(nativeEndian.Uint64(b)), nil
+	case FIELD_TYPE_NET_DECIMAL:
+		return Decimal{
+			Flags: nativeEndian.Uint32(b),
+			Hi: nativeEndian.Uint32(b[4:]),
+			Lo: nativeEndian.Uint32(b[8:]),
+			Mid: nativeEndian.Uint32(b[12:]),
+		}, nil
+	}
+	return nil, fmt.Errorf("field %s: %w: %s", f.Name, ErrUnsupportedType, FieldTypeName(f.Type))
+}
+
+// IsTimeField reports whether the field is one of the time fields. A nil
+// time section has no time field.
+func (ts *TimeSection) IsTimeField(f ItemSectionField) bool {
+	if ts == nil {
+		return false
+	}
+	for _, offset := range ts.Offsets {
+		if offset == f.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// ItemTime returns the time of an item read with ReadBytes, given by its
+// first time field
+func (tf *TeaFile) ItemTime(item []byte) (time.Time, error) {
+	offset, err := tf.timeFieldOffset()
+	if err != nil { return time.Time{}, err }
+	if offset < 0 || offset + 8 > len(item) {
+		return time.Time{}, fmt.Errorf("time field at offset %d outside item of %d bytes", offset, len(item))
+	}
+	return tf.timeSection.Time(int64(nativeEndian.Uint64(item[offset:]))), nil
+}
@@ -0,0 +1,31 @@
+package goteafiles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestItemBounds(t *testing.T) {
+	f := ItemSectionField{Name: "Price", Type: FIELD_TYPE_DOUBLE, Offset: -8}
+	if _, err := f.Value(make([]byte, 16)); err == nil {
+		t.Fatalf("was expecting an error for a negative offset")
+	}
+	f.Offset = 8
+	if v, err := f.Value(make([]byte, 16)); err != nil || v != float64(0) {
+		t.Fatalf("got %v, was expecting 0: %v", v, err)
+	}
+
+	tf, err := OpenRead("test-fixtures/acme.tea", reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	defer tf.Close()
+	if _, err := tf.ItemTime(make([]byte, 4)); err == nil {
+		t.Fatalf("was expecting an error for a short item")
+	}
+	tf.timeSection.Offsets[0] = -8
+	tf.itemSection.Fields[0].Offset = -8
+	if _, err := tf.ItemTime(make([]byte, tf.itemSection.Info.ItemSize)); err == nil {
+		t.Fatalf("was expecting an error for a negative time offset")
+	}
+}
@@ -0,0 +1,90 @@
+package goteafiles
+
+import (
+	"unsafe"
+)
+
+// itemLayout maps the fields of a Go type to the fields of an item section
+// whose offsets differ, such as files written by C and C++ producers with
+// #pragma pack(1). Items are then copied field by field.
+type itemLayout struct {
+	itemSize int
+	dataSize int
+	fields   []layoutField
+}
+
+type layoutField struct {
+	fileOffset int
+	dataOffset int
+	size       int
+}
+
+// newItemLayout maps the fields of the Go type to the fields of the item
+// section by name, the schemas having been checked by diffSchema. It
+// returns nil if both layouts are identical, in which case items can be
+// copied as a whole.
+func newItemLayout(fields []flatField, dataSize uintptr, is *ItemSection) *itemLayout {
+	byName := make(map[string]flatField, len(fields))
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+	layout := &itemLayout{
+		itemSize: int(is.Info.ItemSize),
+		dataSize: int(dataSize),
+	}
+	identical := uintptr(is.Info.ItemSize) == dataSize
+	for _, fileField := range is.Fields {
+		dataField := byName[fileField.Name]
+		if uintptr(fileField.Offset) != dataField.Offset {
+			identical = false
+		}
+		layout.fields = append(layout.fields, layoutField{
+			fileOffset: int(fileField.Offset),
+			dataOffset: int(dataField.Offset),
+			size: int(dataField.Type.Size()),
+		})
+	}
+	if identical {
+		return nil
+	}
+	return layout
+}
+
+// encode copies the fields of the value at ptr to their file offsets in dst
+func (l *itemLayout) encode(dst []byte, ptr uintptr) {
+	src := itemBytes(ptr, l.dataSize)
+	for i := range dst {
+		dst[i] = 0
+	}
+	for _, f := range l.fields {
+		copy(dst[f.fileOffset:f.fileOffset + f.size], src[f.dataOffset:f.dataOffset + f.size])
+	}
+}
+
+// decode copies the fields at their file offsets in src to the value at ptr
+func (l *itemLayout) decode(ptr uintptr, src []byte) {
+	dst := itemBytes(ptr, l.dataSize)
+	for _, f := range l.fields {
+		copy(dst[f.dataOffset:f.dataOffset + f.size], src[f.fileOffset:f.fileOffset + f.size])
+	}
+}
+
+// packItemSection lays the fields out one after the other without padding
+func packItemSection(is *ItemSection, fields []flatField) {
+	var offset int32 = 0
+	for i := range is.Fields {
+		is.Fields[i].Offset = offset
+		offset += int32(fields[i].Type.Size())
+	}
+	is.Info.ItemSize = offset
+}
+
+// itemBytes returns the length bytes at ptr
+func itemBytes(ptr uintptr, length int) []byte {
+	var sl = struct {
+		addr uintptr
+		len  int
+		cap  int
+	}{ptr, length, length}
+	return *(*[]byte)(unsafe.Pointer(&sl))
+}
@@ -0,0 +1,163 @@
+package goteafiles
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// MergeOptions control MergeSorted
+type MergeOptions struct {
+	// Dedup drops items whose fields are identical to an item already
+	// written with the same time
+	Dedup bool
+}
+
+// MergeSorted writes the items of the readers to w, sorted by their first
+// time field. The readers must have the same item and time sections as
+// w, and their items must be sorted by time. Items with the same time are
+// written in the order of the readers. It returns the number of items
+// written.
+func MergeSorted(w *TeaFile, opts MergeOptions, readers ...*TeaFile) (int64, error) {
+	if w.itemSection == nil {
+		return 0, ErrNoItemSection
+	}
+	offset, err := w.timeFieldOffset()
+	if err != nil { return 0, err }
+	itemSize := int(w.itemSection.Info.ItemSize)
+
+	h := &mergeHeap{}
+	for i, r := range readers {
+		err = sameLayout(w, r)
+		if err != nil { return 0, fmt.Errorf("input %d: %v", i, err) }
+		in := &mergeInput{tf: r, index: i, item: make([]byte, itemSize)}
+		ok, err := in.next(offset)
+		if err != nil { return 0, fmt.Errorf("input %d: %v", i, err) }
+		if ok {
+			h.inputs = append(h.inputs, in)
+		}
+	}
+	heap.Init(h)
+
+	var count int64
+	var last int64
+	// Items written with the last time, to find duplicates
+	var written [][]byte
+	for h.Len() > 0 {
+		in := h.inputs[0]
+		duplicate := false
+		if opts.Dedup {
+			if count == 0 || in.ticks != last {
+				written = written[:0]
+			}
+			for _, item := range written {
+				if sameFields(w.itemSection, item, in.item) {
+					duplicate = true
+					break
+				}
+			}
+		}
+		if !duplicate {
+			err = w.WriteBytes(in.item)
+			if err != nil { return count, err }
+			if opts.Dedup {
+				written = append(written, append([]byte{}, in.item...))
+			}
+			count += 1
+			last = in.ticks
+		}
+
+		ok, err := in.next(offset)
+		if err != nil { return count, fmt.Errorf("input %d: %v", in.index, err) }
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	return count, nil
+}
+
+// sameFields reports whether two items have the same field values,
+// ignoring the padding between fields
+func sameFields(is *ItemSection, a []byte, b []byte) bool {
+	for _, f := range is.Fields {
+		end := f.Offset + fieldTypeSizes[f.Type]
+		if !bytes.Equal(a[f.Offset:end], b[f.Offset:end]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameLayout checks that r has the same item layout and time section as w
+func sameLayout(w *TeaFile, r *TeaFile) error {
+	if r.itemSection == nil {
+		return ErrNoItemSection
+	}
+	if r.itemSection.Info.ItemSize != w.itemSection.Info.ItemSize || !reflect.DeepEqual(r.itemSection.Fields, w.itemSection.Fields) {
+		return fmt.Errorf("item section differs from the output")
+	}
+	if r.timeSection == nil || r.timeSection.Epoch != w.timeSection.Epoch || r.timeSection.TicksPerDay != w.timeSection.TicksPerDay || !reflect.DeepEqual(r.timeSection.Offsets, w.timeSection.Offsets) {
+		return fmt.Errorf("time section differs from the output")
+	}
+	return nil
+}
+
+type mergeInput struct {
+	tf    *TeaFile
+	index int
+	item  []byte
+	ticks int64
+	count int64
+}
+
+// next reads the next item of the input, checking items are sorted
+func (in *mergeInput) next(offset int) (bool, error) {
+	err := in.tf.ReadBytes(in.item)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil { return false, err }
+	ticks := int64(nativeEndian.Uint64(in.item[offset:]))
+	if in.count > 0 && ticks < in.ticks {
+		return false, fmt.Errorf("item %d is before the previous item", in.count)
+	}
+	in.ticks = ticks
+	in.count += 1
+	return true, nil
+}
+
+// mergeHeap orders the inputs by the time of their current item, and then
+// by their index
+type mergeHeap struct {
+	inputs []*mergeInput
+}
+
+func (h *mergeHeap) Len() int {
+	return len(h.inputs)
+}
+
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.inputs[i], h.inputs[j]
+	if a.ticks != b.ticks {
+		return a.ticks < b.ticks
+	}
+	return a.index < b.index
+}
+
+func (h *mergeHeap) Swap(i, j int) {
+	h.inputs[i], h.inputs[j] = h.inputs[j], h.inputs[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	h.inputs = append(h.inputs, x.(*mergeInput))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	in := h.inputs[len(h.inputs) - 1]
+	h.inputs = h.inputs[:len(h.inputs) - 1]
+	return in
+}
@@ -0,0 +1,88 @@
+package goteafiles
+
+import (
+	"reflect"
+	"testing"
+)
+
+// tickBuffer writes ticks of the given times, in ms, and prices to memory
+// and opens them for reading
+func tickBuffer(t *testing.T, times []int64, prices []float64) *TeaFile {
+	tf, err := CreateBuffer(
+		WithDataType(reflect.TypeOf(Tick{})),
+		WithTimeFields(719162, 86400000, []int32{0}),
+		WithContentDescription("ticks"))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	for i := range times {
+		err = tf.Write(Tick{Time: times[i], Price: prices[i]})
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	tf, err = OpenReadBytes(tf.Bytes(), reflect.TypeOf(Tick{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	return tf
+}
+
+func readTicks(t *testing.T, tf *TeaFile) []Tick {
+	tf, err := OpenReadBytes(tf.Bytes(), reflect.TypeOf(Tick{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	var ticks []Tick
+	for {
+		val, err := tf.Read()
+		if err != nil {
+			break
+		}
+		ticks = append(ticks, val.(reflect.Value).Elem().Interface().(Tick))
+	}
+	return ticks
+}
+
+func TestMergeSorted(t *testing.T) {
+	for _, dedup := range []bool{false, true} {
+		a := tickBuffer(t, []int64{0, 2, 4}, []float64{1, 1, 1})
+		b := tickBuffer(t, []int64{1, 2, 2, 3}, []float64{2, 2, 1, 2})
+		w, err := CreateBuffer(WithSectionsOf(a))
+		if err != nil {
+			t.Fatalf("error creating TeaFile: %v", err)
+		}
+		n, err := MergeSorted(w, MergeOptions{Dedup: dedup}, a, b)
+		if err != nil {
+			t.Fatalf("error merging: %v", err)
+		}
+		want := []Tick{{0, 1}, {1, 2}, {2, 1}, {2, 2}, {2, 1}, {3, 2}, {4, 1}}
+		if dedup {
+			want = append(want[:4], want[5:]...)
+		}
+		if got := readTicks(t, w); n != int64(len(want)) || !reflect.DeepEqual(got, want) {
+			t.Fatalf("dedup %v: got %d items %v, was expecting %v", dedup, n, got, want)
+		}
+	}
+
+	a := tickBuffer(t, []int64{0, 2, 1}, []float64{1, 1, 1})
+	w, err := CreateBuffer(WithSectionsOf(a))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	if _, err := MergeSorted(w, MergeOptions{}, a); err == nil {
+		t.Fatalf("was expecting an error for unsorted items")
+	}
+
+	other, err := CreateBuffer(WithDataType(reflect.TypeOf(Data{})), WithTimeFields(719162, 86400000, []int32{0}))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	other, err = OpenReadBytes(other.Bytes(), nil)
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	if _, err := MergeSorted(w, MergeOptions{}, other); err == nil {
+		t.Fatalf("was expecting an error for a different item section")
+	}
+}
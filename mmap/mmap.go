@@ -14,9 +14,10 @@ import (
 type MMapReader struct {
 	ItemCount int
 	data      []byte
-	ptr       uintptr
+	base      unsafe.Pointer
 	size      int64
 	itemSize  int64
+	mapped    bool
 }
 
 // Close closes the reader.
@@ -26,6 +27,9 @@ func (r *MMapReader) Close() error {
 	}
 	data := r.data
 	r.data = nil
+	if !r.mapped {
+		return nil
+	}
 	runtime.SetFinalizer(r, nil)
 	return syscall.Munmap(data)
 }
@@ -37,7 +41,7 @@ func (r *MMapReader) Len() int {
 
 // GetItem returns a point to the item at index idx
 func (r *MMapReader) GetItem(idx int) unsafe.Pointer {
-	return unsafe.Pointer(r.ptr + uintptr(idx * int(r.itemSize)))
+	return unsafe.Pointer(uintptr(r.base) + uintptr(idx * int(r.itemSize)))
 }
 
 // Open memory-maps the file for reading.
@@ -61,19 +65,38 @@ func Open(f *os.File, offset int64, size int64, itemSize int64) (*MMapReader, er
 		return nil, fmt.Errorf("mmap: size is too large")
 	}
 
-	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(offset + size), syscall.PROT_READ, syscall.MAP_SHARED)
 	if err != nil {
 		return nil, err
 	}
 
-	ptr := uintptr(unsafe.Pointer(&data[0])) + uintptr(offset)
 	r := &MMapReader{
 		ItemCount: int(size / itemSize),
 		data: data,
-		ptr: ptr,
+		base: unsafe.Pointer(&data[offset]),
 		size: size,
-		itemSize: itemSize}
+		itemSize: itemSize,
+		mapped: true}
 
 	runtime.SetFinalizer(r, (*MMapReader).Close)
 	return r, nil
 }
+
+
+// FromBytes reads items already held in memory, without copy. The data
+// must not be modified while the reader is in use.
+func FromBytes(data []byte, offset int64, size int64, itemSize int64) (*MMapReader, error) {
+	if size + offset > int64(len(data)) {
+		return nil, fmt.Errorf("mmap: size is too large")
+	}
+	if size == 0 {
+		return &MMapReader{itemSize: itemSize}, nil
+	}
+	r := &MMapReader{
+		ItemCount: int(size / itemSize),
+		data: data,
+		base: unsafe.Pointer(&data[offset]),
+		size: size,
+		itemSize: itemSize}
+	return r, nil
+}
\ No newline at end of file
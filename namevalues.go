@@ -0,0 +1,121 @@
+package goteafiles
+
+import (
+	"fmt"
+	"github.com/satori/go.uuid"
+	"math"
+	"time"
+)
+
+// GetInt32 returns the int32 value associated with name
+func (nvs NameValues) GetInt32(name string) (int32, error) {
+	val, err := nvs.get(name)
+	if err != nil { return 0, err }
+	v, ok := val.(int32)
+	if !ok { return 0, nameValueTypeError(name, val, "int32") }
+	return v, nil
+}
+
+// GetFloat64 returns the float64 value associated with name
+func (nvs NameValues) GetFloat64(name string) (float64, error) {
+	val, err := nvs.get(name)
+	if err != nil { return 0, err }
+	v, ok := val.(float64)
+	if !ok { return 0, nameValueTypeError(name, val, "float64") }
+	return v, nil
+}
+
+// GetString returns the text value associated with name
+func (nvs NameValues) GetString(name string) (string, error) {
+	val, err := nvs.get(name)
+	if err != nil { return "", err }
+	v, ok := val.(string)
+	if !ok { return "", nameValueTypeError(name, val, "string") }
+	return v, nil
+}
+
+// GetUUID returns the UUID value associated with name
+func (nvs NameValues) GetUUID(name string) (uuid.UUID, error) {
+	val, err := nvs.get(name)
+	if err != nil { return uuid.Nil, err }
+	v, ok := val.(uuid.UUID)
+	if !ok { return uuid.Nil, nameValueTypeError(name, val, "uuid.UUID") }
+	return v, nil
+}
+
+// GetUint64 returns the uint64 value associated with name
+func (nvs NameValues) GetUint64(name string) (uint64, error) {
+	val, err := nvs.get(name)
+	if err != nil { return 0, err }
+	v, ok := val.(uint64)
+	if !ok { return 0, nameValueTypeError(name, val, "uint64") }
+	return v, nil
+}
+
+func (nvs NameValues) get(name string) (interface{}, error) {
+	val, ok := nvs.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("no name value %s", name)
+	}
+	return val, nil
+}
+
+func nameValueTypeError(name string, val interface{}, expected string) error {
+	return fmt.Errorf("name value %s is a %T, not a %s", name, val, expected)
+}
+
+// coerceNameValue converts a Go value to the type used to store it:
+//   int32, float64, string, uuid.UUID and uint64 are stored as they are
+//   int, int8, int16 and int64 are stored as int32, or as uint64 when
+//   they are above its range, and must not be below it
+//   uint, uint8, uint16 and uint32 are stored as uint64
+//   float32 is stored as float64
+//   bool is stored as an int32 of value 0 or 1
+//   time.Time is stored as text in RFC 3339 format with nanoseconds
+//   []byte is stored as text
+// Any other type is an error.
+func coerceNameValue(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case int32, float64, string, uuid.UUID, uint64:
+		return v, nil
+	case int:
+		return coerceInt32(int64(v))
+	case int8:
+		return int32(v), nil
+	case int16:
+		return int32(v), nil
+	case int64:
+		return coerceInt32(v)
+	case uint:
+		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
+	case uint16:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case float32:
+		return float64(v), nil
+	case bool:
+		if v {
+			return int32(1), nil
+		}
+		return int32(0), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []byte:
+		return string(v), nil
+	default:
+		return nil, fmt.Errorf("%w for name value: %T", ErrUnsupportedType, val)
+	}
+}
+
+func coerceInt32(v int64) (interface{}, error) {
+	if v < math.MinInt32 {
+		return nil, fmt.Errorf("integer %d overflows int32", v)
+	}
+	if v > math.MaxInt32 {
+		return uint64(v), nil
+	}
+	return int32(v), nil
+}
@@ -0,0 +1,273 @@
+package goteafiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Schema describes the items of a file created without a Go type. Fields
+// are laid out in order with the alignment of a C struct, each field being
+// aligned on its size, or 4 bytes for decimals, unless Packed is set.
+type Schema struct {
+	TypeName string
+	Fields   []SchemaField
+	Packed   bool
+}
+
+// SchemaField is a field of a Schema, its type being one of the
+// FIELD_TYPE_* constants
+type SchemaField struct {
+	Name string
+	Type int32
+}
+
+// fieldTypeAlignment returns the alignment of a field type
+func fieldTypeAlignment(fieldType int32) int32 {
+	if fieldType == FIELD_TYPE_NET_DECIMAL {
+		return 4
+	}
+	return fieldTypeSizes[fieldType]
+}
+
+// ItemSection lays out the fields of the schema
+func (s Schema) ItemSection() (*ItemSection, error) {
+	if len(s.Fields) == 0 {
+		return nil, fmt.Errorf("schema without fields")
+	}
+	is := &ItemSection{}
+	is.Info.ItemTypeName = s.TypeName
+	is.Info.FieldCount = int32(len(s.Fields))
+	var offset int32
+	var align int32 = 1
+	names := make(map[string]bool)
+	for i, f := range s.Fields {
+		size, ok := fieldTypeSizes[f.Type]
+		if !ok {
+			return nil, fmt.Errorf("field %s: %w: %s", f.Name, ErrUnsupportedType, FieldTypeName(f.Type))
+		}
+		if f.Name == "" || names[f.Name] {
+			return nil, fmt.Errorf("field %d: empty or duplicate name %q", i, f.Name)
+		}
+		names[f.Name] = true
+		if !s.Packed {
+			a := fieldTypeAlignment(f.Type)
+			if offset % a != 0 {
+				offset += a - offset % a
+			}
+			if a > align {
+				align = a
+			}
+		}
+		is.Fields = append(is.Fields, ItemSectionField{
+			Index: int32(i),
+			Type: f.Type,
+			Offset: offset,
+			Name: f.Name,
+		})
+		offset += size
+	}
+	if offset % align != 0 {
+		offset += align - offset % align
+	}
+	is.Info.ItemSize = offset
+	return is, nil
+}
+
+// WithSchema describes the items with a schema instead of a Go type.
+// Items are then written with WriteBytes and read with ReadBytes.
+func WithSchema(s Schema) TeaFileConfig {
+	return configFunc(func (tf *TeaFile) error {
+		is, err := s.ItemSection()
+		if err != nil { return err }
+		tf.dataType = nil
+		tf.itemSection = is
+		return nil
+	})
+}
+
+// fieldTypeAliases are the lower case field type names accepted by
+// ParseFieldType in addition to the names returned by FieldTypeName
+var fieldTypeAliases = map[string]int32{
+	"byte"   : FIELD_TYPE_UINT8,
+	"float32": FIELD_TYPE_FLOAT,
+	"float64": FIELD_TYPE_DOUBLE,
+	"decimal": FIELD_TYPE_NET_DECIMAL,
+}
+
+// ParseFieldType returns the field type of the given name, as returned by
+// FieldTypeName, in any case, or one of byte, float32, float64 and decimal
+func ParseFieldType(name string) (int32, error) {
+	if fieldType, ok := fieldTypeAliases[strings.ToLower(name)]; ok {
+		return fieldType, nil
+	}
+	for fieldType, fieldTypeName := range fieldTypeNames {
+		if strings.EqualFold(fieldTypeName, name) && fieldType != FIELD_TYPE_CUSTOM {
+			return fieldType, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: field type %q", ErrUnsupportedType, name)
+}
+
+// SchemaOf returns the schema of an item section, which must be laid out
+// as a schema would, aligned or packed
+func SchemaOf(is *ItemSection) (Schema, error) {
+	s := Schema{TypeName: is.Info.ItemTypeName}
+	for _, f := range is.Fields {
+		s.Fields = append(s.Fields, SchemaField{Name: f.Name, Type: f.Type})
+	}
+	for _, packed := range []bool{false, true} {
+		s.Packed = packed
+		layout, err := s.ItemSection()
+		if err != nil { return s, err }
+		if reflect.DeepEqual(layout, is) {
+			return s, nil
+		}
+	}
+	return s, fmt.Errorf("item section %s is not laid out as a schema", is.Info.ItemTypeName)
+}
+
+// ParseSchema parses the text or JSON form of a schema. The text form
+// lists the fields as name:type, separated by commas, optionally enclosed
+// in parentheses after the type name and preceded by "packed":
+//
+//	packed Tick(Time:Int64, Price:Double, Volume:Int32)
+//
+// Types are parsed by ParseFieldType. The space separated field names and
+// Python struct format used by the Python TeaFiles library are accepted
+// too, the format following the names:
+//
+//	Acme(Time Price Volume Prob Prib) QBQBQ
+//
+// The JSON form is the one produced by MarshalJSON, and is recognized by
+// its opening brace. Both forms are checked as WithSchema would.
+func ParseSchema(text string) (Schema, error) {
+	var s Schema
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "{") {
+		err := json.Unmarshal([]byte(text), &s)
+		if err != nil { return s, err }
+		_, err = s.ItemSection()
+		return s, err
+	}
+	if strings.HasPrefix(text, "packed ") {
+		s.Packed = true
+		text = strings.TrimSpace(text[len("packed "):])
+	}
+	if i := strings.Index(text, "("); i >= 0 {
+		j := strings.LastIndex(text, ")")
+		if j < i {
+			return s, fmt.Errorf("schema %q: missing closing parenthesis", text)
+		}
+		if format := strings.TrimSpace(text[j+1:]); format != "" {
+			if s.Packed {
+				return s, fmt.Errorf("schema %q: a format gives its own packing", text)
+			}
+			return schemaFromFormat(strings.TrimSpace(text[:i]), text[i+1:j], format)
+		}
+		s.TypeName = strings.TrimSpace(text[:i])
+		text = text[i+1:j]
+	}
+	for _, field := range strings.Split(text, ",") {
+		parts := strings.Split(field, ":")
+		if len(parts) != 2 {
+			return s, fmt.Errorf("schema field %q is not name:type", strings.TrimSpace(field))
+		}
+		fieldType, err := ParseFieldType(strings.TrimSpace(parts[1]))
+		if err != nil { return s, err }
+		s.Fields = append(s.Fields, SchemaField{Name: strings.TrimSpace(parts[0]), Type: fieldType})
+	}
+	_, err := s.ItemSection()
+	return s, err
+}
+
+// schemaFromFormat returns the schema of the items described by field
+// names and a Python struct format. Formats with pad bytes, or without
+// the trailing padding of an aligned schema, have no schema and are only
+// supported by WithFormat.
+func schemaFromFormat(typeName string, fieldNames string, format string) (Schema, error) {
+	is, err := ItemSectionFromFormat(typeName, fieldNames, format)
+	if err != nil { return Schema{}, err }
+	s, err := SchemaOf(is)
+	if err != nil {
+		return s, fmt.Errorf("format %q has no schema, use WithFormat: %v", format, err)
+	}
+	return s, nil
+}
+
+// String returns the text form of the schema, as parsed by ParseSchema
+func (s Schema) String() string {
+	var b strings.Builder
+	if s.Packed {
+		b.WriteString("packed ")
+	}
+	b.WriteString(s.TypeName)
+	b.WriteString("(")
+	for i, f := range s.Fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(f.Name)
+		b.WriteString(":")
+		b.WriteString(FieldTypeName(f.Type))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+type schemaJSON struct {
+	TypeName string            `json:"typeName,omitempty"`
+	Packed   bool              `json:"packed,omitempty"`
+	Fields   []schemaFieldJSON `json:"fields,omitempty"`
+	Names    string            `json:"names,omitempty"`
+	Format   string            `json:"format,omitempty"`
+}
+
+type schemaFieldJSON struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// MarshalJSON returns the JSON form of the schema, field types being
+// given by name. The field names and Python struct format of the schema
+// are added when its field types have a format character:
+//
+//	{"typeName": "Tick", "fields": [{"name": "Time", "type": "Int64"}], "names": "Time", "format": "q"}
+func (s Schema) MarshalJSON() ([]byte, error) {
+	js := schemaJSON{TypeName: s.TypeName, Packed: s.Packed}
+	for _, f := range s.Fields {
+		js.Fields = append(js.Fields, schemaFieldJSON{Name: f.Name, Type: FieldTypeName(f.Type)})
+	}
+	if is, err := s.ItemSection(); err == nil {
+		if format, names, err := is.Format(); err == nil {
+			js.Format, js.Names = format, names
+		}
+	}
+	return json.Marshal(js)
+}
+
+// UnmarshalJSON reads the JSON form of a schema, given by its fields or
+// by the field names and Python struct format, or both if they agree
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var js schemaJSON
+	err := json.Unmarshal(data, &js)
+	if err != nil { return err }
+	*s = Schema{TypeName: js.TypeName, Packed: js.Packed}
+	for _, f := range js.Fields {
+		fieldType, err := ParseFieldType(f.Type)
+		if err != nil { return err }
+		s.Fields = append(s.Fields, SchemaField{Name: f.Name, Type: fieldType})
+	}
+	if js.Format == "" && js.Names == "" {
+		_, err = s.ItemSection()
+		return err
+	}
+	fs, err := schemaFromFormat(js.TypeName, js.Names, js.Format)
+	if err != nil { return err }
+	if js.Fields != nil && !reflect.DeepEqual(fs, *s) {
+		return fmt.Errorf("schema fields do not match format %q", js.Format)
+	}
+	*s = fs
+	return nil
+}
@@ -0,0 +1,167 @@
+package goteafiles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchemaLayout(t *testing.T) {
+	schema := Schema{
+		TypeName: "Quote",
+		Fields: []SchemaField{
+			{Name: "Time", Type: FIELD_TYPE_INT64},
+			{Name: "Side", Type: FIELD_TYPE_UINT8},
+			{Name: "Size", Type: FIELD_TYPE_INT32},
+			{Name: "Price", Type: FIELD_TYPE_NET_DECIMAL},
+			{Name: "Flag", Type: FIELD_TYPE_INT16},
+		},
+	}
+	is, err := schema.ItemSection()
+	if err != nil {
+		t.Fatalf("error laying out schema: %v", err)
+	}
+	// Same layout as the Go struct
+	type Quote struct {
+		Time  int64
+		Side  uint8
+		Size  int32
+		Price Decimal
+		Flag  int16
+	}
+	typ := reflect.TypeOf(Quote{})
+	for i, f := range is.Fields {
+		if int(f.Offset) != int(typ.Field(i).Offset) {
+			t.Fatalf("field %s at offset %d, was expecting %d", f.Name, f.Offset, typ.Field(i).Offset)
+		}
+	}
+	if int(is.Info.ItemSize) != int(typ.Size()) {
+		t.Fatalf("got item size %d, was expecting %d", is.Info.ItemSize, typ.Size())
+	}
+
+	schema.Packed = true
+	is, err = schema.ItemSection()
+	if err != nil {
+		t.Fatalf("error laying out schema: %v", err)
+	}
+	if is.Fields[4].Offset != 29 || is.Info.ItemSize != 31 {
+		t.Fatalf("got wrong packed layout: %+v", is)
+	}
+
+	schema.Fields = append(schema.Fields, SchemaField{Name: "Time", Type: FIELD_TYPE_INT64})
+	if _, err := schema.ItemSection(); err == nil {
+		t.Fatalf("was expecting an error for a duplicate field")
+	}
+	if _, err := ParseFieldType("Double"); err != nil {
+		t.Fatalf("error parsing field type: %v", err)
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	want := Schema{
+		TypeName: "Tick",
+		Fields: []SchemaField{
+			{Name: "Time", Type: FIELD_TYPE_INT64},
+			{Name: "Price", Type: FIELD_TYPE_DOUBLE},
+			{Name: "Size", Type: FIELD_TYPE_NET_DECIMAL},
+		},
+	}
+	texts := []string{
+		"Tick(Time:Int64, Price:Double, Size:NetDecimal)",
+		" Tick( Time : int64,Price:float64 , Size:decimal ) ",
+		`{"typeName": "Tick", "fields": [{"name": "Time", "type": "Int64"}, {"name": "Price", "type": "Double"}, {"name": "Size", "type": "NetDecimal"}]}`,
+	}
+	for _, text := range texts {
+		s, err := ParseSchema(text)
+		if err != nil {
+			t.Fatalf("error parsing %q: %v", text, err)
+		}
+		if !reflect.DeepEqual(s, want) {
+			t.Fatalf("%q: got schema %v, was expecting %v", text, s, want)
+		}
+	}
+
+	// The printers produce what the parser reads
+	want.Packed = true
+	s, err := ParseSchema(want.String())
+	if err != nil || !reflect.DeepEqual(s, want) {
+		t.Fatalf("%q: got schema %v: %v", want.String(), s, err)
+	}
+	b, err := want.MarshalJSON()
+	if err != nil {
+		t.Fatalf("error marshaling schema: %v", err)
+	}
+	s, err = ParseSchema(string(b))
+	if err != nil || !reflect.DeepEqual(s, want) {
+		t.Fatalf("%s: got schema %v: %v", b, s, err)
+	}
+	if s, err := ParseSchema("Time:UInt64, Price:Byte"); err != nil || s.TypeName != "" || len(s.Fields) != 2 {
+		t.Fatalf("got schema %v: %v", s, err)
+	}
+
+	// The names and format of the Python library
+	acme := Schema{
+		TypeName: "Acme",
+		Fields: []SchemaField{
+			{Name: "Time", Type: FIELD_TYPE_UINT64},
+			{Name: "Price", Type: FIELD_TYPE_UINT8},
+			{Name: "Volume", Type: FIELD_TYPE_UINT64},
+			{Name: "Prob", Type: FIELD_TYPE_UINT8},
+			{Name: "Prib", Type: FIELD_TYPE_UINT64},
+		},
+	}
+	texts = []string{
+		"Acme(Time Price Volume Prob Prib) QBQBQ",
+		`{"typeName": "Acme", "names": "Time Price Volume Prob Prib", "format": "QBQBQ"}`,
+	}
+	for _, text := range texts {
+		s, err := ParseSchema(text)
+		if err != nil || !reflect.DeepEqual(s, acme) {
+			t.Fatalf("%q: got schema %v: %v", text, s, err)
+		}
+	}
+	b, err = acme.MarshalJSON()
+	if err != nil {
+		t.Fatalf("error marshaling schema: %v", err)
+	}
+	if s, err := ParseSchema(string(b)); err != nil || !reflect.DeepEqual(s, acme) {
+		t.Fatalf("%s: got schema %v: %v", b, s, err)
+	}
+
+	for _, text := range []string{
+		"Tick(Time:Int64",
+		"Time Int64",
+		"Time:Int128",
+		"Time:Int64, Time:Int64",
+		"Tick(Time Price) qxd",
+		"Tick(Time Time) qd",
+		`{"fields": [{"name": "Time", "type": "Int64"}, {"name": "Time", "type": "Int64"}]}`,
+		`{"fields": [{"name": "Time", "type": "Int128"}]}`,
+		`{"fields": []}`,
+		`{"fields": [{"name": "Time", "type": "Int64"}], "names": "Time", "format": "d"}`,
+	} {
+		if _, err := ParseSchema(text); err == nil {
+			t.Fatalf("was expecting an error parsing %q", text)
+		}
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	tf, err := OpenRead("test-fixtures/acme.tea", nil)
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	defer tf.Close()
+	s, err := SchemaOf(tf.ItemSection())
+	if err != nil {
+		t.Fatalf("error getting schema: %v", err)
+	}
+	if s.String() != "Data(Time:UInt64, Price:UInt8, Volume:UInt64, Prob:UInt8, Prib:UInt64)" {
+		t.Fatalf("got wrong schema %s", s)
+	}
+
+	is, _ := s.ItemSection()
+	is.Fields[1].Offset = 9
+	if _, err := SchemaOf(is); err == nil {
+		t.Fatalf("was expecting an error for a custom layout")
+	}
+}
@@ -6,8 +6,178 @@ import (
 	"github.com/satori/go.uuid"
 	"io"
 	"reflect"
+	"sort"
 )
 
+// Section is implemented by every section that can be stored in the
+// header of a TeaFile.
+type Section interface {
+	ID() int32
+	Read(r io.Reader, order binary.ByteOrder) error
+	Write(w io.Writer, order binary.ByteOrder) error
+	Size() int64
+}
+
+type sectionKind struct {
+	id     int32
+	create func() Section
+	get    func(tf *TeaFile) Section
+	set    func(tf *TeaFile, s Section)
+}
+
+// sectionKinds lists the known sections in the order they are written,
+// which is the order used by the reference implementation: item section,
+// content description, name values and time section, followed by the
+// custom sections of this library. Adding a section type only requires
+// adding it here.
+var sectionKinds = []sectionKind{
+	{
+		id:     ITEM_SECTION_ID,
+		create: func() Section { return &ItemSection{} },
+		get: func(tf *TeaFile) Section {
+			if tf.itemSection == nil { return nil }
+			return tf.itemSection
+		},
+		set: func(tf *TeaFile, s Section) { tf.itemSection = s.(*ItemSection) },
+	},
+	{
+		id:     CONTENT_DESCRIPTION_SECTION_ID,
+		create: func() Section { return &ContentDescriptionSection{} },
+		get: func(tf *TeaFile) Section {
+			if tf.contentDescriptionSection == nil { return nil }
+			return tf.contentDescriptionSection
+		},
+		set: func(tf *TeaFile, s Section) { tf.contentDescriptionSection = s.(*ContentDescriptionSection) },
+	},
+	{
+		id:     NAME_VALUE_SECTION_ID,
+		create: func() Section { return &NameValueSection{} },
+		get: func(tf *TeaFile) Section {
+			if tf.nameValueSection == nil { return nil }
+			return tf.nameValueSection
+		},
+		set: func(tf *TeaFile, s Section) { tf.nameValueSection = s.(*NameValueSection) },
+	},
+	{
+		id:     TIME_SECTION_ID,
+		create: func() Section { return &TimeSection{} },
+		get: func(tf *TeaFile) Section {
+			if tf.timeSection == nil { return nil }
+			return tf.timeSection
+		},
+		set: func(tf *TeaFile, s Section) { tf.timeSection = s.(*TimeSection) },
+	},
+	{
+		id:     CHUNK_SECTION_ID,
+		create: func() Section { return &ChunkSection{} },
+		get: func(tf *TeaFile) Section {
+			if tf.chunkSection == nil { return nil }
+			return tf.chunkSection
+		},
+		set: func(tf *TeaFile, s Section) { tf.chunkSection = s.(*ChunkSection) },
+	},
+	{
+		id:     ENCODING_SECTION_ID,
+		create: func() Section { return &EncodingSection{} },
+		get: func(tf *TeaFile) Section {
+			if tf.encodingSection == nil { return nil }
+			return tf.encodingSection
+		},
+		set: func(tf *TeaFile, s Section) { tf.encodingSection = s.(*EncodingSection) },
+	},
+	{
+		id:     CHECKSUM_SECTION_ID,
+		create: func() Section { return &ChecksumSection{} },
+		get: func(tf *TeaFile) Section {
+			if tf.checksumSection == nil { return nil }
+			return tf.checksumSection
+		},
+		set: func(tf *TeaFile, s Section) { tf.checksumSection = s.(*ChecksumSection) },
+	},
+}
+
+func findSectionKind(id int32) (sectionKind, bool) {
+	for _, kind := range sectionKinds {
+		if kind.id == id {
+			return kind, true
+		}
+	}
+	return sectionKind{}, false
+}
+
+// sections returns the sections present in the file, in writing order
+func (tf *TeaFile) sections() []Section {
+	var sections []Section
+	for _, kind := range sectionKinds {
+		if s := kind.get(tf); s != nil {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// sectionsSize returns the number of bytes taken by the given sections,
+// including the section ID and next section offset of each of them
+func sectionsSize(sections []Section) int64 {
+	var size int64 = 0
+	for _, s := range sections {
+		// Section ID
+		size += 4
+		// Next Section Offset
+		size += 4
+		size += s.Size()
+	}
+	return size
+}
+
+func writeSection(w io.Writer, order binary.ByteOrder, s Section) error {
+	// The size of name values that cannot be stored is unknown
+	if nv, ok := s.(*NameValueSection); ok {
+		err := nv.validate()
+		if err != nil { return err }
+	}
+	err := binary.Write(w, order, s.ID())
+	if err != nil { return err }
+	err = binary.Write(w, order, int32(s.Size()))
+	if err != nil { return err }
+	return s.Write(w, order)
+}
+
+// readSection reads the section ID, the next section offset and the
+// section itself, and checks the section consumed exactly the announced
+// number of bytes. The offset of the section in the file is only used to
+// report errors.
+func readSection(r io.Reader, order binary.ByteOrder, offset int64) (Section, error) {
+	var sectionID int32
+	err := binary.Read(r, order, &sectionID)
+	if err != nil { return nil, truncated(err) }
+	var nextSectionOffset int32
+	err = binary.Read(r, order, &nextSectionOffset)
+	if err != nil { return nil, truncated(err) }
+
+	sectionErr := func(read int64, err error) error {
+		return &SectionError{
+			ID: sectionID,
+			Offset: offset,
+			Size: int64(nextSectionOffset),
+			Read: read,
+			Err: err,
+		}
+	}
+	kind, ok := findSectionKind(sectionID)
+	if !ok {
+		return nil, sectionErr(0, ErrUnknownSection)
+	}
+	s := kind.create()
+	cr := &countingReader{r: r, size: int64(nextSectionOffset)}
+	err = s.Read(cr, order)
+	if err != nil { return nil, sectionErr(cr.n, truncated(err)) }
+	if cr.n != int64(nextSectionOffset) {
+		return nil, sectionErr(cr.n, ErrSectionSize)
+	}
+	return s, nil
+}
+
 type ItemSection struct {
 	Info  ItemSectionInfo
 	Fields []ItemSectionField
@@ -26,6 +196,10 @@ type ItemSectionField struct {
 	Name   string
 }
 
+func (is *ItemSection) ID() int32 {
+	return ITEM_SECTION_ID
+}
+
 func (is *ItemSection) Read(r io.Reader, order binary.ByteOrder) error {
 	err := binary.Read(r, order, &is.Info.ItemSize)
 	if err != nil { return err }
@@ -87,18 +261,106 @@ func (is *ItemSection) Size() int64 {
 	return size
 }
 
+// NameValue is a single entry of a name value section
+type NameValue struct {
+	Name  string
+	Value interface{}
+}
+
+// NameValues is an ordered list of name values. The order is the insertion
+// order when created in Go, and the file order when read from a file, so
+// that writing a file back produces the same bytes.
+type NameValues []NameValue
+
+// NameValuesFromMap returns the name values of the map sorted by name
+func NameValuesFromMap(m map[string]interface{}) NameValues {
+	nvs := make(NameValues, 0, len(m))
+	for name, value := range m {
+		nvs = append(nvs, NameValue{Name: name, Value: value})
+	}
+	return nvs.Sorted()
+}
+
+// Get returns the value associated with name
+func (nvs NameValues) Get(name string) (interface{}, bool) {
+	for _, nv := range nvs {
+		if nv.Name == name {
+			return nv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set replaces the value associated with name, keeping its position, or
+// appends it if the name is not present
+func (nvs *NameValues) Set(name string, value interface{}) {
+	for i := range *nvs {
+		if (*nvs)[i].Name == name {
+			(*nvs)[i].Value = value
+			return
+		}
+	}
+	*nvs = append(*nvs, NameValue{Name: name, Value: value})
+}
+
+// Names returns the names in order
+func (nvs NameValues) Names() []string {
+	names := make([]string, len(nvs))
+	for i, nv := range nvs {
+		names[i] = nv.Name
+	}
+	return names
+}
+
+// Sorted returns a copy of the name values sorted by name
+func (nvs NameValues) Sorted() NameValues {
+	sorted := make(NameValues, len(nvs))
+	copy(sorted, nvs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// Map returns the name values as a map, losing the order
+func (nvs NameValues) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(nvs))
+	for _, nv := range nvs {
+		m[nv.Name] = nv.Value
+	}
+	return m
+}
+
 type NameValueSection struct {
-	NameValues map[string]interface{}
+	NameValues NameValues
 }
 
 func defaultNameValueSection() *NameValueSection {
 	return &NameValueSection{
-		NameValues: make(map[string]interface{}),
+		NameValues: NameValues{},
 	}
 }
 
+func (nv *NameValueSection) ID() int32 {
+	return NAME_VALUE_SECTION_ID
+}
+
+// validate coerces every value to its spec type, and returns an error if
+// a value has no spec type
+func (nv *NameValueSection) validate() error {
+	// Coerce a copy, the slice may belong to the caller
+	nvs := make(NameValues, len(nv.NameValues))
+	for i, entry := range nv.NameValues {
+		val, err := coerceNameValue(entry.Value)
+		if err != nil { return fmt.Errorf("name value %s: %w", entry.Name, err) }
+		nvs[i] = NameValue{Name: entry.Name, Value: val}
+	}
+	nv.NameValues = nvs
+	return nil
+}
+
 func (nv *NameValueSection) Read(r io.Reader, order binary.ByteOrder) error {
-	nv.NameValues = make(map[string]interface{})
+	nv.NameValues = NameValues{}
 	var count int32
 	err := binary.Read(r, order, &count)
 	if err != nil { return err }
@@ -114,18 +376,18 @@ func (nv *NameValueSection) Read(r io.Reader, order binary.ByteOrder) error {
 			var value int32
 			err = binary.Read(r, order, &value)
 			if err != nil { return err }
-			nv.NameValues[name] = value
+			nv.NameValues = append(nv.NameValues, NameValue{name, value})
 
 		case NAME_VALUE_TEXT:
 			value, err := readText(r, order)
 			if err != nil { return err }
-			nv.NameValues[name] = value
+			nv.NameValues = append(nv.NameValues, NameValue{name, value})
 
 		case NAME_VALUE_DOUBLE:
 			var value float64
 			err = binary.Read(r, order, &value)
 			if err != nil { return err }
-			nv.NameValues[name] = value
+			nv.NameValues = append(nv.NameValues, NameValue{name, value})
 
 		case NAME_VALUE_UUID:
 			bytes := make([]byte, 16)
@@ -133,17 +395,17 @@ func (nv *NameValueSection) Read(r io.Reader, order binary.ByteOrder) error {
 			if err != nil { return err }
 			value, err := uuid.FromBytes(bytes)
 			if err != nil { return err }
-			nv.NameValues[name] = value
+			nv.NameValues = append(nv.NameValues, NameValue{name, value})
 
 		case NAME_VALUE_UINT64:
 			var value uint64
 			err = binary.Read(r, order, &value)
 			if err != nil { return err }
-			nv.NameValues[name] = value
+			nv.NameValues = append(nv.NameValues, NameValue{name, value})
 
 
 		default:
-			return fmt.Errorf("unknown name value kind %d", kind)
+			return fmt.Errorf("%w %d", ErrUnknownNameValueKind, kind)
 		}
 	}
 	return nil
@@ -153,11 +415,13 @@ func (nv *NameValueSection) Write(w io.Writer, order binary.ByteOrder) error {
 	var count = int32(len(nv.NameValues))
 	err := binary.Write(w, order, count)
 	if err != nil { return err }
-	for key, val := range nv.NameValues {
-		err = writeText(w, order, key)
+	for _, entry := range nv.NameValues {
+		err = writeText(w, order, entry.Name)
 		if err != nil { return err }
+		val, err := coerceNameValue(entry.Value)
+		if err != nil { return fmt.Errorf("name value %s: %w", entry.Name, err) }
 		nameValueType := typeToNameValueType[reflect.TypeOf(val).String()]
-		err := binary.Write(w, order, nameValueType)
+		err = binary.Write(w, order, nameValueType)
 		if err != nil { return err }
 		if nameValueType == NAME_VALUE_TEXT {
 			err = writeText(w, order, val.(string))
@@ -173,12 +437,17 @@ func (nv *NameValueSection) Size() int64 {
 	var size int64 = 0
 	// Count
 	size += 4
-	for name, val := range nv.NameValues {
+	for _, entry := range nv.NameValues {
 		// Name
-		size += textSize(name)
+		size += textSize(entry.Name)
 		// ValueType
 		size += 4
-		// Value
+		// Value, unsupported values being reported by validate, which
+		// writeSection calls first
+		val, err := coerceNameValue(entry.Value)
+		if err != nil {
+			continue
+		}
 		if reflect.TypeOf(val).Kind() == reflect.String {
 			size += textSize(val.(string))
 		} else {
@@ -205,11 +474,18 @@ func defaultTimeSection() *TimeSection {
 	}
 }
 
+func (ts *TimeSection) ID() int32 {
+	return TIME_SECTION_ID
+}
+
 func (ts *TimeSection) Read(r io.Reader, order binary.ByteOrder) error {
 	err := binary.Read(r, order, &ts.Epoch)
 	if err != nil { return err }
 	err = binary.Read(r, order, &ts.TicksPerDay)
 	if err != nil { return err }
+	if ts.TicksPerDay <= 0 {
+		return fmt.Errorf("%d ticks per day: %w", ts.TicksPerDay, ErrCorrupt)
+	}
 	err = binary.Read(r, order, &ts.Count)
 	if err != nil { return err }
 
@@ -263,6 +539,10 @@ func defaultContentDescriptionSection() *ContentDescriptionSection {
 	}
 }
 
+func (s *ContentDescriptionSection) ID() int32 {
+	return CONTENT_DESCRIPTION_SECTION_ID
+}
+
 func (s *ContentDescriptionSection) Read(r io.Reader, order binary.ByteOrder) error {
 	var err error
 	s.ContentDescription, err = readText(r, order)
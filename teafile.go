@@ -1,9 +1,14 @@
 package goteafiles
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"github.com/melaurent/goteafiles/mmap"
+	"github.com/satori/go.uuid"
 	//"golang.org/x/exp/mmap"
 	"os"
 	"reflect"
@@ -37,135 +42,409 @@ type TeaFile struct {
 	mode                      int
 	fileName                  string
 	file                      *os.File
+	reader                    io.Reader
+	writer                    io.Writer
+	seeker                    io.Seeker
+	closer                    io.Closer
+	size                      func() (int64, error)
+	memory                    []byte
+	buffer                    *bytes.Buffer
 	dataType                  reflect.Type
 	header                    Header
 	itemSection               *ItemSection
 	nameValueSection          *NameValueSection
 	timeSection               *TimeSection
 	contentDescriptionSection *ContentDescriptionSection
+	explicitLayout            bool
+	layout                    *itemLayout
+	compression               Compression
+	chunkSection              *ChunkSection
+	chunkWriter               *chunkWriter
+	chunkReader               *chunkReader
+	encodingSection           *EncodingSection
+	encodingOverrides         map[string]FieldEncoding
+	checksumSection           *ChecksumSection
+	checksumWriter            *checksumWriter
+	configErr                 error
+	item                      int64
 }
 
 func Create(fileName string, configs ...TeaFileConfig) (*TeaFile, error) {
+	tf := &TeaFile{
+		mode: os.O_WRONLY,
+		fileName: fileName,
+		compression: CompressionOf(fileName),
+	}
+	err := tf.configure(configs)
+	if err != nil { return nil, err }
+
 	f, err := os.Create(fileName)
 	if err != nil {
 		return nil, err
 	}
+	tf.file = f
+	tf.writer = f
+	tf.seeker = f
+	tf.closer = f
+	if tf.compression != NoCompression {
+		cw, err := tf.compression.newWriter(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		tf.file = nil
+		tf.seeker = nil
+		tf.writer = cw
+		tf.closer = closers{cw, f}
+	}
+
+	err = tf.writeHeader()
+	if err == nil {
+		err = tf.startChecksums(tf.header.ItemStart, nil)
+	}
+	if err == nil {
+		err = tf.startChunks()
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return tf, nil
+}
+
+// NewWriter creates a TeaFile written to w. Closing the TeaFile does not
+// close w. Block compressed and checksummed files require w to be an
+// io.WriteSeeker positioned at the start of the file. With WithCompression
+// the whole file is compressed as it is written.
+func NewWriter(w io.Writer, configs ...TeaFileConfig) (*TeaFile, error) {
 	tf := &TeaFile{
 		mode: os.O_WRONLY,
-		fileName: fileName,
-		file: f,
+		writer: w,
 	}
-	for _, config := range configs {
-		config(tf)
+	if ws, ok := w.(io.WriteSeeker); ok {
+		tf.seeker = ws
 	}
-	tf.header.SectionCount = 0
-	tf.header.ItemStart = int64(reflect.TypeOf(tf.header).Size())
-	tf.header.ItemEnd = 0
-	tf.header.MagicValue = 0x0d0e0a0402080500
-
-	if tf.itemSection != nil {
-		err = tf.checkDataType()
+	err := tf.configure(configs)
+	if err != nil { return nil, err }
+	if tf.compression != NoCompression {
+		cw, err := tf.compression.newWriter(w)
 		if err != nil { return nil, err }
+		tf.seeker = nil
+		tf.writer = cw
+		tf.closer = cw
+	}
+
+	err = tf.writeHeader()
+	if err != nil { return nil, err }
+
+	err = tf.startChecksums(tf.header.ItemStart, nil)
+	if err != nil { return nil, err }
+
+	err = tf.startChunks()
+	if err != nil { return nil, err }
+
+	return tf, nil
+}
 
-		tf.header.SectionCount += 1
-		// Section ID
-		tf.header.ItemStart += 4
-		// Next Section Offset
-		tf.header.ItemStart += 4
-		// Item Section
-		tf.header.ItemStart += tf.itemSection.Size()
+// startChunks prepares the writing of a block compressed file, whose
+// header is completed on Close
+func (tf *TeaFile) startChunks() error {
+	if tf.chunkSection == nil {
+		return nil
+	}
+	if tf.seeker == nil {
+		return fmt.Errorf("block compression: %w", ErrNotSeekable)
 	}
+	var err error
+	tf.chunkWriter, err = newChunkWriter(tf, tf.header.ItemStart)
+	return err
+}
 
+// CreateBuffer creates a TeaFile in memory. Its content is returned by
+// Bytes and can be read back with OpenReadBytes, or with NewDecoder when
+// it is compressed.
+func CreateBuffer(configs ...TeaFileConfig) (*TeaFile, error) {
+	buffer := &bytes.Buffer{}
+	tf, err := NewWriter(buffer, configs...)
+	if err != nil { return nil, err }
+	tf.buffer = buffer
+	return tf, nil
+}
+
+// configure applies the configs of a new file, checks them and computes
+// its header
+func (tf *TeaFile) configure(configs []TeaFileConfig) error {
+	for _, config := range configs {
+		config(tf)
+		if tf.configErr != nil { return tf.configErr }
+	}
+	if tf.itemSection != nil && tf.dataType != nil {
+		err := tf.checkDataType()
+		if err != nil { return err }
+	}
 	if tf.nameValueSection != nil {
-		tf.header.SectionCount += 1
-		// Section ID
-		tf.header.ItemStart += 4
-		// Next Section Offset
-		tf.header.ItemStart += 4
-		// Name Value Section
-		tf.header.ItemStart += tf.nameValueSection.Size()
+		err := tf.nameValueSection.validate()
+		if err != nil { return err }
 	}
-
-	if tf.timeSection != nil {
-		tf.header.SectionCount += 1
-		// Section ID
-		tf.header.ItemStart += 4
-		// Next Section Offset
-		tf.header.ItemStart += 4
-		// Time Section
-		tf.header.ItemStart += tf.timeSection.Size()
+	if tf.encodingOverrides != nil {
+		var err error
+		tf.encodingSection, err = tf.buildEncodingSection()
+		if err != nil { return err }
+		if tf.chunkSection == nil {
+			tf.chunkSection = &ChunkSection{ChunkItems: defaultChunkItems}
+		}
 	}
-
-	if tf.contentDescriptionSection != nil {
-		tf.header.SectionCount += 1
-		// Section ID
-		tf.header.ItemStart += 4
-		// Next Section Offset
-		tf.header.ItemStart += 4
-		// Content Description Section
-		tf.header.ItemStart += tf.contentDescriptionSection.Size()
+	if tf.chunkSection != nil && tf.compression != NoCompression {
+		return fmt.Errorf("block compression of a compressed file: %w", ErrCompressed)
 	}
 
-	// Align ItemStart on 8 bytes
-	paddingBytes := 8 - tf.header.ItemStart % 8
+	sections := tf.sections()
+	tf.header.MagicValue = 0x0d0e0a0402080500
+	tf.header.SectionCount = int64(len(sections))
+	tf.header.ItemStart = itemStart(sections)
+	tf.header.ItemEnd = 0
+	return nil
+}
 
-	tf.header.ItemStart += paddingBytes
+func OpenRead(fileName string, dataType reflect.Type, configs ...TeaFileConfig) (*TeaFile, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return openReadFile(f, fileName, dataType, configs)
+}
 
-	err = tf.writeHeader()
-	if err != nil { return nil, err }
+// openReadCompressed reads the header of a compressed file from r, closing
+// closer, if not nil, on error and with the TeaFile. Items can then only
+// be read sequentially.
+func openReadCompressed(r io.Reader, closer io.Closer, fileName string, c Compression, dataType reflect.Type, configs []TeaFileConfig) (*TeaFile, error) {
+	dr, err := c.newReader(bufio.NewReader(r))
+	if err != nil {
+		if closer != nil {
+			_ = closer.Close()
+		}
+		return nil, err
+	}
+	cs := closers{dr}
+	if closer != nil {
+		cs = append(cs, closer)
+	}
+	tf := &TeaFile{
+		mode: os.O_RDONLY,
+		fileName: fileName,
+		reader: bufio.NewReader(dr),
+		closer: cs,
+		dataType: dataType,
+		compression: c,
+	}
+
+	err = tf.open(configs)
+	if err != nil {
+		_ = tf.closer.Close()
+		return nil, err
+	}
 
 	return tf, nil
 }
 
-func OpenRead(fileName string, dataType reflect.Type) (*TeaFile, error) {
-	f, err := os.Open(fileName)
+// openReadFile reads the header of an opened file, compressed or not,
+// closing it on error
+func openReadFile(f *os.File, fileName string, dataType reflect.Type, configs []TeaFileConfig) (*TeaFile, error) {
+	c, err := sniffSeeker(f)
 	if err != nil {
+		_ = f.Close()
 		return nil, err
 	}
+	if c != NoCompression {
+		return openReadCompressed(f, f, fileName, c, dataType, configs)
+	}
 	tf := &TeaFile{
 		mode: os.O_RDONLY,
 		fileName: fileName,
 		file: f,
+		reader: f,
+		seeker: f,
+		closer: f,
+		size: fileSize(f),
 		dataType: dataType,
 	}
 
-	err = tf.readHeader()
+	err = tf.open(configs)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return tf, nil
+}
+
+// NewReader reads a TeaFile of the given size from r
+func NewReader(r io.ReaderAt, size int64, dataType reflect.Type, configs ...TeaFileConfig) (*TeaFile, error) {
+	return NewReadSeeker(io.NewSectionReader(r, 0, size), dataType, configs...)
+}
+
+// NewReadSeeker reads a TeaFile from r, whose position must be the start
+// of the file. Compressed files are read sequentially. Closing the
+// TeaFile does not close r.
+func NewReadSeeker(r io.ReadSeeker, dataType reflect.Type, configs ...TeaFileConfig) (*TeaFile, error) {
+	c, err := sniffSeeker(r)
 	if err != nil { return nil, err }
+	if c != NoCompression {
+		return openReadCompressed(r, nil, "", c, dataType, configs)
+	}
+	tf := &TeaFile{
+		mode: os.O_RDONLY,
+		reader: r,
+		seeker: r,
+		size: seekerSize(r),
+		dataType: dataType,
+	}
 
-	err = tf.checkDataType()
+	err = tf.open(configs)
 	if err != nil { return nil, err }
 
 	return tf, nil
 }
 
-func OpenWrite(fileName string, dataType reflect.Type) (*TeaFile, error) {
-	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0666)
+// OpenReadBytes reads a TeaFile held in memory. The items of uncompressed
+// files can be mapped with OpenReadableMapping without copy.
+func OpenReadBytes(data []byte, dataType reflect.Type, configs ...TeaFileConfig) (*TeaFile, error) {
+	tf, err := NewReader(bytes.NewReader(data), int64(len(data)), dataType, configs...)
+	if err != nil { return nil, err }
+	if tf.compression != NoCompression {
+		return tf, nil
+	}
+	tf.memory = data
+	return tf, nil
+}
+
+func OpenWrite(fileName string, dataType reflect.Type, configs ...TeaFileConfig) (*TeaFile, error) {
+	if c := CompressionOf(fileName); c != NoCompression {
+		return nil, fmt.Errorf("appending to %s: %w", fileName, ErrCompressed)
+	}
+	f, err := os.OpenFile(fileName, os.O_RDWR, 0666)
 	if err != nil { return nil, err }
 
 	tf := &TeaFile{
 		mode: os.O_WRONLY,
 		fileName: fileName,
 		file: f,
+		reader: f,
+		writer: f,
+		closer: f,
+		size: fileSize(f),
 		dataType: dataType,
 	}
 
-	err = tf.readHeader()
-	if err != nil { return nil, err }
-
-	err = tf.checkDataType()
-	if err != nil { return nil, err }
+	err = tf.open(configs)
+	if err == nil && tf.chunkSection != nil {
+		err = fmt.Errorf("appending to %s: %w", fileName, ErrCompressed)
+	}
+	if err == nil && tf.checksumSection != nil {
+		err = tf.reopenChecksums(f)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
 
 	_, err = f.Seek(0, 2)
-	if err != nil { return nil, err }
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
 
 	return tf, nil
 }
 
+// reopenChecksums drops the checksum table of a file opened for
+// appending, so that items are written in its place and the checksums
+// are maintained
+func (tf *TeaFile) reopenChecksums(f *os.File) error {
+	tf.seeker = f
+	sums, err := tf.readChecksums()
+	if err != nil { return err }
+	err = f.Truncate(tf.checksumSection.TableOffset)
+	if err != nil { return err }
+	return tf.startChecksums(tf.checksumSection.TableOffset, sums)
+}
+
+// seekerSize returns the size of the seeker content, leaving its position
+// unchanged
+func seekerSize(s io.Seeker) func() (int64, error) {
+	return func() (int64, error) {
+		pos, err := s.Seek(0, io.SeekCurrent)
+		if err != nil { return 0, err }
+		end, err := s.Seek(0, io.SeekEnd)
+		if err != nil { return 0, err }
+		_, err = s.Seek(pos, io.SeekStart)
+		return end, err
+	}
+}
+
+func fileSize(f *os.File) func() (int64, error) {
+	return func() (int64, error) {
+		fi, err := f.Stat()
+		if err != nil { return 0, err }
+		return fi.Size(), nil
+	}
+}
+
+// open reads the header of an opened file, applies the configs and checks
+// the data type against the item section. A nil data type skips the
+// check, for readers working on raw items.
+func (tf *TeaFile) open(configs []TeaFileConfig) error {
+	err := tf.readHeader()
+	if err != nil { return err }
+
+	for _, config := range configs {
+		config(tf)
+		if tf.configErr != nil { return tf.configErr }
+	}
+
+	if tf.itemSection == nil {
+		return ErrNoItemSection
+	}
+	if tf.itemSection.Info.ItemSize <= 0 || tf.itemSection.Info.ItemSize > maxItemSize {
+		return fmt.Errorf("item size %d: %w", tf.itemSection.Info.ItemSize, ErrCorrupt)
+	}
+	if tf.encodingSection != nil && tf.chunkSection == nil {
+		return fmt.Errorf("encoded items without chunk section")
+	}
+	if tf.chunkSection != nil && tf.mode == os.O_RDONLY {
+		tf.chunkReader, err = newChunkReader(tf)
+		if err != nil { return err }
+	}
+	if tf.dataType == nil {
+		return nil
+	}
+	return tf.checkDataType()
+}
+
 func (tf *TeaFile) GetFileName() string {
 	return tf.fileName
 }
 
+// Bytes returns the content of a TeaFile created or opened in memory, and
+// nil otherwise
+func (tf *TeaFile) Bytes() []byte {
+	if tf.buffer != nil {
+		return tf.buffer.Bytes()
+	}
+	return tf.memory
+}
+
 func (tf *TeaFile) GetNameValues() map[string]interface{} {
+	if tf.nameValueSection != nil {
+		return tf.nameValueSection.NameValues.Map()
+	} else {
+		return nil
+	}
+}
+
+// GetOrderedNameValues returns the name values in file order
+func (tf *TeaFile) GetOrderedNameValues() NameValues {
 	if tf.nameValueSection != nil {
 		return tf.nameValueSection.NameValues
 	} else {
@@ -173,18 +452,85 @@ func (tf *TeaFile) GetNameValues() map[string]interface{} {
 	}
 }
 
+// GetInt32 returns the int32 name value associated with name
+func (tf *TeaFile) GetInt32(name string) (int32, error) {
+	return tf.GetOrderedNameValues().GetInt32(name)
+}
+
+// GetFloat64 returns the float64 name value associated with name
+func (tf *TeaFile) GetFloat64(name string) (float64, error) {
+	return tf.GetOrderedNameValues().GetFloat64(name)
+}
+
+// GetString returns the text name value associated with name
+func (tf *TeaFile) GetString(name string) (string, error) {
+	return tf.GetOrderedNameValues().GetString(name)
+}
+
+// GetUUID returns the UUID name value associated with name
+func (tf *TeaFile) GetUUID(name string) (uuid.UUID, error) {
+	return tf.GetOrderedNameValues().GetUUID(name)
+}
+
+// GetUint64 returns the uint64 name value associated with name
+func (tf *TeaFile) GetUint64(name string) (uint64, error) {
+	return tf.GetOrderedNameValues().GetUint64(name)
+}
+
+// Header returns the fixed part of the file header
+func (tf *TeaFile) Header() Header {
+	return tf.header
+}
+
+// Sections returns the sections of the file, in writing order
+func (tf *TeaFile) Sections() []Section {
+	return tf.sections()
+}
+
+// ItemSection returns the item section, or nil if the file has none
+func (tf *TeaFile) ItemSection() *ItemSection {
+	return tf.itemSection
+}
+
+// TimeSection returns the time section, or nil if the file has none
+func (tf *TeaFile) TimeSection() *TimeSection {
+	return tf.timeSection
+}
+
+// ContentDescription returns the content description, or an empty string
+// if the file has none
+func (tf *TeaFile) ContentDescription() string {
+	if tf.contentDescriptionSection == nil {
+		return ""
+	}
+	return tf.contentDescriptionSection.ContentDescription
+}
+
 func (tf *TeaFile) OpenReadableMapping() (*mmap.MMapReader, error) {
 	if tf.mode == os.O_WRONLY {
-		return nil, fmt.Errorf("memory mapping in write mode not supported")
+		return nil, fmt.Errorf("memory mapping in write mode: %w", ErrWrongMode)
+	}
+	if tf.layout != nil {
+		return nil, fmt.Errorf("memory mapping requires the file layout to match the data type")
+	}
+	if tf.compression != NoCompression || tf.chunkSection != nil {
+		return nil, fmt.Errorf("memory mapping: %w", ErrCompressed)
 	}
-
-	_, err := tf.file.Seek(0, 0)
-	if err != nil { return nil, err }
 
 	size, err := tf.getItemAreaSize()
 	if err != nil { return nil, err }
 	if size == 0 {
-		return nil, fmt.Errorf("no data")
+		return nil, ErrEmptyFile
+	}
+	if tf.memory != nil {
+		return mmap.FromBytes(
+			tf.memory,
+			tf.header.ItemStart,
+			size,
+			int64(tf.itemSection.Info.ItemSize))
+	}
+	if tf.file == nil {
+		return nil, ErrNotMappable
 	}
 	reader, err := mmap.Open(
 		tf.file,
@@ -196,63 +542,189 @@ func (tf *TeaFile) OpenReadableMapping() (*mmap.MMapReader, error) {
 
 func (tf *TeaFile) Read() (interface{}, error) {
 	if tf.mode == os.O_WRONLY {
-		return nil, fmt.Errorf("reading in write mode not supported")
+		return nil, fmt.Errorf("reading in write mode: %w", ErrWrongMode)
+	}
+	if tf.itemSection == nil {
+		return nil, ErrNoItemSection
+	}
+	if tf.dataType == nil {
+		return nil, ErrNoDataType
 	}
 	val := reflect.New(tf.dataType)
 	length := int(tf.itemSection.Info.ItemSize)
-	var sl = struct {
-		addr uintptr
-		len  int
-		cap  int
-	}{val.Pointer(), length, length}
-	b := *(*[]byte)(unsafe.Pointer(&sl))
-	_, err := tf.file.Read(b)
+	if tf.layout != nil {
+		b := make([]byte, length)
+		err := tf.readItem(b)
+		if err != nil { return val, err }
+		tf.layout.decode(val.Pointer(), b)
+		return val, nil
+	}
+	b := itemBytes(val.Pointer(), length)
+	err := tf.readItem(b)
 
 	return val, err
 }
 
+// ReadBytes reads the next item into b, in the layout described by the
+// item section. It allows reading files without a Go type, opened with a
+// nil data type.
+func (tf *TeaFile) ReadBytes(b []byte) error {
+	if tf.mode == os.O_WRONLY {
+		return fmt.Errorf("reading in write mode: %w", ErrWrongMode)
+	}
+	if tf.itemSection == nil {
+		return ErrNoItemSection
+	}
+	if len(b) != int(tf.itemSection.Info.ItemSize) {
+		return fmt.Errorf("buffer of %d bytes for items of %d bytes", len(b), tf.itemSection.Info.ItemSize)
+	}
+	return tf.readItem(b)
+}
+
+// readItem reads the bytes of the next item, in file layout, stopping at
+// the end of the item area when the header sets one
+func (tf *TeaFile) readItem(b []byte) error {
+	if tf.chunkReader != nil {
+		return tf.chunkReader.readItem(tf, b)
+	}
+	if tf.header.ItemEnd != 0 && tf.header.ItemStart + (tf.item + 1) * int64(len(b)) > tf.header.ItemEnd {
+		return io.EOF
+	}
+	_, err := io.ReadFull(tf.reader, b)
+	if err == nil {
+		tf.item += 1
+	}
+	return err
+}
+
+// WriteBytes writes an item given in the layout described by the item
+// section. It allows writing files created with a schema instead of a Go
+// type.
+func (tf *TeaFile) WriteBytes(b []byte) error {
+	if tf.mode == os.O_RDONLY {
+		return fmt.Errorf("writing in read mode: %w", ErrWrongMode)
+	}
+	if tf.itemSection == nil {
+		return ErrNoItemSection
+	}
+	if len(b) != int(tf.itemSection.Info.ItemSize) {
+		return fmt.Errorf("item of %d bytes for items of %d bytes", len(b), tf.itemSection.Info.ItemSize)
+	}
+	return tf.writeItem(b)
+}
+
+// writeItem writes the bytes of an item, in file layout
+func (tf *TeaFile) writeItem(b []byte) error {
+	if tf.chunkWriter != nil {
+		return tf.chunkWriter.writeItem(tf.writer, b)
+	}
+	_, err := tf.writer.Write(b)
+	return err
+}
+
 func (tf *TeaFile) Write(val interface{}) error {
 	if tf.mode == os.O_RDONLY {
-		return fmt.Errorf("writing in reading mode not supported")
+		return fmt.Errorf("writing in read mode: %w", ErrWrongMode)
 	}
 	if tf.itemSection == nil {
-		return fmt.Errorf("this file has no item section")
+		return ErrNoItemSection
+	}
+	if tf.dataType == nil {
+		return ErrNoDataType
 	}
 	if reflect.TypeOf(val) != tf.dataType {
-		return fmt.Errorf("was expecting %s, got %s", tf.dataType, reflect.TypeOf(val))
+		return fmt.Errorf("%w: was expecting %s, got %s", ErrTypeMismatch, tf.dataType, reflect.TypeOf(val))
 	}
 
 	vp := reflect.New(reflect.TypeOf(val))
 	vp.Elem().Set(reflect.ValueOf(val))
 	ptr := vp.Pointer()
 	length := int(tf.itemSection.Info.ItemSize)
-	var sl = struct {
-		addr uintptr
-		len  int
-		cap  int
-	}{ptr, length, length}
-	b := *(*[]byte)(unsafe.Pointer(&sl))
-	_, err := tf.file.Write(b)
-
-	return err
+	var b []byte
+	if tf.layout != nil {
+		b = make([]byte, length)
+		tf.layout.encode(b, ptr)
+	} else {
+		b = itemBytes(ptr, length)
+	}
+	return tf.writeItem(b)
 }
 
 func (tf *TeaFile) SeekItem(idx int64) error {
 	if tf.mode == os.O_WRONLY {
-		return fmt.Errorf("seeking in write mode not supported")
+		return fmt.Errorf("seeking in write mode: %w", ErrWrongMode)
 	}
 	if tf.itemSection == nil {
-		return fmt.Errorf("no item section defined, cannot seek on item size")
+		return ErrNoItemSection
+	}
+	if tf.compression != NoCompression {
+		return fmt.Errorf("seeking: %w", ErrCompressed)
+	}
+	if tf.seeker == nil {
+		return ErrNotSeekable
+	}
+	if idx < 0 {
+		return fmt.Errorf("seeking negative item %d", idx)
 	}
-	_, err := tf.file.Seek(tf.header.ItemStart + idx * int64(tf.itemSection.Info.ItemSize), 0)
+	if tf.chunkReader != nil {
+		return tf.chunkReader.seek(idx)
+	}
+	_, err := tf.seeker.Seek(tf.header.ItemStart + idx * int64(tf.itemSection.Info.ItemSize), 0)
+	tf.item = idx
 	return err
 }
 
+// Close closes the underlying file. TeaFiles backed by readers and
+// writers given by the caller leave them open. Block compressed and
+// checksummed files are completed before closing.
 func (tf *TeaFile) Close() error {
-	return tf.file.Close()
+	err := tf.finish()
+	if err != nil {
+		if tf.closer != nil {
+			_ = tf.closer.Close()
+		}
+		return err
+	}
+	if tf.closer == nil {
+		return nil
+	}
+	return tf.closer.Close()
+}
+
+// finish writes the trailers of block compressed and checksummed files,
+// and the completed header over the first one
+func (tf *TeaFile) finish() error {
+	if tf.chunkWriter == nil && tf.checksumWriter == nil {
+		return nil
+	}
+	if tf.chunkWriter != nil {
+		err := tf.chunkWriter.finish(tf)
+		tf.chunkWriter = nil
+		if err != nil { return err }
+	}
+	if tf.checksumWriter != nil {
+		err := tf.checksumWriter.finish(tf)
+		tf.checksumWriter = nil
+		if err != nil { return err }
+		tf.checksumSection.HeaderChecksum, err = tf.headerChecksum()
+		if err != nil { return err }
+	}
+
+	_, err := tf.seeker.Seek(0, io.SeekStart)
+	if err != nil { return err }
+	err = tf.writeHeader()
+	if err != nil { return err }
+	_, err = tf.seeker.Seek(0, io.SeekEnd)
+	return err
 }
 
 func (tf *TeaFile) ItemCount() (int, error) {
+	if tf.itemSection == nil {
+		return 0, ErrNoItemSection
+	}
+	if tf.chunkSection != nil {
+		return int(tf.chunkSection.ItemCount), nil
+	}
 	areaSize, err := tf.getItemAreaSize()
 	if err != nil {
 		return 0, err
@@ -264,167 +736,103 @@ func (tf *TeaFile) ItemCount() (int, error) {
 func (tf *TeaFile) getItemAreaSize() (int64, error) {
 	var size int64
 	if tf.header.ItemEnd == 0 {
-		fi, err := tf.file.Stat()
+		if tf.compression != NoCompression {
+			return 0, fmt.Errorf("item area size: %w", ErrCompressed)
+		}
+		if tf.size == nil {
+			return 0, ErrNotSeekable
+		}
+		fileSize, err := tf.size()
 		if err != nil { return 0, err }
-		size = fi.Size() - tf.header.ItemStart
+		size = fileSize - tf.header.ItemStart
 	} else {
 		size = tf.header.ItemEnd - tf.header.ItemStart
 	}
 	return size, nil
 }
 
+// maxItemSize bounds the item size read from a file, as item buffers are
+// allocated from it
+const maxItemSize = 1 << 20
+
+// headerSize is the size of the fixed part of the header
+var headerSize = int64(reflect.TypeOf(Header{}).Size())
+
+// itemStart returns the offset of the item area for a file holding the
+// given sections, aligned on 8 bytes
+func itemStart(sections []Section) int64 {
+	start := headerSize + sectionsSize(sections)
+	if start % 8 != 0 {
+		start += 8 - start % 8
+	}
+	return start
+}
+
 func (tf *TeaFile) readHeader() error {
-	err := binary.Read(tf.file, nativeEndian, &tf.header)
-	if err != nil { return err }
+	err := binary.Read(tf.reader, nativeEndian, &tf.header)
+	if err == io.EOF {
+		return ErrEmptyFile
+	}
+	if err != nil { return truncated(err) }
 	if tf.header.MagicValue != 0x0d0e0a0402080500 {
-		return fmt.Errorf("byteordermark mismatch")
+		return ErrBadMagic
 	}
 
+	cr := &countingReader{r: tf.reader, n: headerSize}
 	for i := 0; i < int(tf.header.SectionCount); i++ {
-		var sectionID int32
-		err = binary.Read(tf.file, nativeEndian, &sectionID)
+		s, err := readSection(cr, nativeEndian, cr.n)
 		if err != nil { return err }
-		var nextSectionOffset int32
-		err = binary.Read(tf.file, nativeEndian, &nextSectionOffset)
-		if err != nil { return err }
-
-		beforeSection, err := tf.file.Seek(0, 1)
-		if err != nil { return err }
-
-		switch sectionID {
-		case ITEM_SECTION_ID:
-			tf.itemSection = &ItemSection{}
-			err = tf.itemSection.Read(tf.file, nativeEndian)
-			if err != nil { return err }
-
-		case CONTENT_DESCRIPTION_SECTION_ID:
-			tf.contentDescriptionSection = &ContentDescriptionSection{}
-			err = tf.contentDescriptionSection.Read(tf.file, nativeEndian)
-			if err != nil { return err }
-
-		case NAME_VALUE_SECTION_ID:
-			tf.nameValueSection = &NameValueSection{}
-			err = tf.nameValueSection.Read(tf.file, nativeEndian)
-			if err != nil { return err }
-
-		case TIME_SECTION_ID:
-			tf.timeSection = &TimeSection{}
-			err = tf.timeSection.Read(tf.file, nativeEndian)
-			if err != nil { return err }
-
-		default:
-			return fmt.Errorf("unknown section ID %d", sectionID)
-		}
-
-		afterSection, err := tf.file.Seek(0, 1)
+		kind, _ := findSectionKind(s.ID())
+		kind.set(tf, s)
+	}
+	// Items start after the sections, in the file
+	if tf.header.ItemStart < cr.n {
+		return fmt.Errorf("ItemStart %d before the end of the sections at %d: %w", tf.header.ItemStart, cr.n, ErrCorrupt)
+	}
+	if tf.size != nil {
+		size, err := tf.size()
 		if err != nil { return err }
-		if (afterSection - beforeSection) != int64(nextSectionOffset) {
-			return fmt.Errorf("section reads too few or too many bytes")
+		if tf.header.ItemStart > size {
+			return fmt.Errorf("ItemStart %d after the end of the file at %d: %w", tf.header.ItemStart, size, ErrCorrupt)
 		}
 	}
-	position, err := tf.file.Seek(0, 1)
-	if err != nil { return err }
 
-	bytesToSkip := tf.header.ItemStart - position
-	_, err = tf.file.Seek(bytesToSkip, 1)
-	if err != nil { return err }
-
-	return nil
+	if tf.seeker != nil {
+		_, err = tf.seeker.Seek(tf.header.ItemStart, 0)
+		return err
+	}
+	_, err = io.CopyN(ioutil.Discard, cr, tf.header.ItemStart - cr.n)
+	return truncated(err)
 }
 
 func (tf *TeaFile) writeHeader() error {
-	var currOffset int32 = 0
-	err := binary.Write(tf.file, nativeEndian, tf.header)
-	if err != nil { return err }
-	currOffset += int32(reflect.TypeOf(tf.header).Size())
-
-	if tf.itemSection != nil {
-		sectionSize := int32(tf.itemSection.Size())
-		err = binary.Write(tf.file, nativeEndian, ITEM_SECTION_ID)
-		if err != nil { return err }
-		currOffset += 4
-		err = binary.Write(tf.file, nativeEndian, sectionSize)
-		if err != nil { return err }
-		currOffset += 4
-		err = tf.itemSection.Write(tf.file, nativeEndian)
-		if err != nil { return err }
-		currOffset += sectionSize
-	}
-
-	if tf.contentDescriptionSection != nil {
-		sectionSize := int32(tf.contentDescriptionSection.Size())
-		err = binary.Write(tf.file, nativeEndian, CONTENT_DESCRIPTION_SECTION_ID)
-		if err != nil { return err }
-		currOffset += 4
-		err = binary.Write(tf.file, nativeEndian, sectionSize)
-		if err != nil { return err }
-		currOffset += 4
-		err = tf.contentDescriptionSection.Write(tf.file, nativeEndian)
-		if err != nil { return err }
-		currOffset += sectionSize
-	}
-
-	if tf.nameValueSection != nil {
-		sectionSize := int32(tf.nameValueSection.Size())
-		err = binary.Write(tf.file, nativeEndian, NAME_VALUE_SECTION_ID)
-		if err != nil { return err }
-		currOffset += 4
-		err = binary.Write(tf.file, nativeEndian, sectionSize)
-		if err != nil { return err }
-		currOffset += 4
-		err = tf.nameValueSection.Write(tf.file, nativeEndian)
-		if err != nil { return err }
-		currOffset += sectionSize
-	}
+	return tf.encodeHeader(tf.writer)
+}
 
-	if tf.timeSection != nil {
-		sectionSize := int32(tf.timeSection.Size())
-		err = binary.Write(tf.file, nativeEndian, TIME_SECTION_ID)
-		if err != nil { return err }
-		currOffset += 4
-		err = binary.Write(tf.file, nativeEndian, sectionSize)
-		if err != nil { return err }
-		currOffset += 4
-		err = tf.timeSection.Write(tf.file, nativeEndian)
-		if err != nil { return err }
-		currOffset += sectionSize
-	}
+// encodeHeader writes the header, sections and padding to w
+func (tf *TeaFile) encodeHeader(w io.Writer) error {
+	err := binary.Write(w, nativeEndian, tf.header)
+	if err != nil { return err }
 
-	var paddingByte uint8 = 0
-	for; int64(currOffset) != tf.header.ItemStart; {
-		err = binary.Write(tf.file, nativeEndian, paddingByte)
+	sections := tf.sections()
+	for _, s := range sections {
+		err = writeSection(w, nativeEndian, s)
 		if err != nil { return err }
-		currOffset += 1
 	}
 
-	return nil
+	padding := make([]byte, tf.header.ItemStart - headerSize - sectionsSize(sections))
+	return binary.Write(w, nativeEndian, padding)
 }
 
-// Check if the data type corresponds to the file description
+// Check if the data type corresponds to the file description, returning
+// a *SchemaMismatchError listing the differing fields if it does not
 func (tf *TeaFile) checkDataType() error {
-	n := tf.dataType.NumField()
-	var fields []reflect.StructField
-	for i := 0; i < n; i++ {
-		if tf.dataType.Field(i).Name != "_" {
-			fields = append(fields, tf.dataType.Field(i))
-		}
-	}
-	if len(fields) != len(tf.itemSection.Fields) {
-		return fmt.Errorf("given type has %d fields, was expecting %d", n, len(tf.itemSection.Fields))
-	}
-	for i := 0; i < len(fields); i++ {
-		dataField := fields[i]
-		fileField := tf.itemSection.Fields[i]
-		if dataField.Type.Kind() != fieldTypeToKind[fileField.Type] {
-			return fmt.Errorf("was not expecting %v", dataField.Type)
-		}
-		if dataField.Offset != uintptr(fileField.Offset) {
-			return fmt.Errorf(
-				"got different offsets for field %d: %d %d",
-				i,
-				dataField.Offset,
-				fileField.Offset)
-		}
+	fields, err := flattenType(tf.dataType)
+	if err != nil { return err }
+	err = diffSchema(tf.dataType, fields, tf.itemSection, tf.explicitLayout)
+	if err != nil { return err }
+	if tf.explicitLayout {
+		tf.layout = newItemLayout(fields, tf.dataType.Size(), tf.itemSection)
 	}
 	return nil
-}
\ No newline at end of file
+}
@@ -1,7 +1,9 @@
 package goteafiles
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
@@ -30,9 +32,9 @@ func TestCreate(t *testing.T) {
 		WithDataType(reflect.TypeOf(Data{})),
 		WithContentDescription("prices of acme at NYSE"),
 		WithTimeFields(719162, 86400000, []int32{0}),
-		WithNameValues(map[string]interface{} {
-			"decimals": int32(2),
-			"url"     : "www.acme.com",
+		WithOrderedNameValues(NameValues{
+			{Name: "url", Value: "www.acme.com"},
+			{Name: "decimals", Value: int32(2)},
 		}))
 	if err != nil {
 		t.Fatalf("error creating TeaFile: %v", err)
@@ -63,6 +65,13 @@ func TestCreate(t *testing.T) {
 		t.Fatalf("error opening golden TeaFile: %v", err)
 	}
 
+	if goldTf.header != tf.header {
+		t.Fatalf(
+			"got different header: %v, %v",
+			goldTf.header,
+			tf.header)
+	}
+
 	if !reflect.DeepEqual(goldTf.itemSection, tf.itemSection) {
 		t.Fatalf(
 			"got different item section: %v, %v",
@@ -90,6 +99,58 @@ func TestCreate(t *testing.T) {
 			goldTf.timeSection,
 			tf.timeSection)
 	}
+
+	goldBytes, err := ioutil.ReadFile("test-fixtures/acme.tea")
+	if err != nil {
+		t.Fatalf("error reading golden TeaFile: %v", err)
+	}
+	fileBytes, err := ioutil.ReadFile("test.tea")
+	if err != nil {
+		t.Fatalf("error reading TeaFile: %v", err)
+	}
+	if !bytes.Equal(goldBytes, fileBytes) {
+		t.Fatalf("got different bytes than the golden TeaFile")
+	}
+
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+}
+
+func TestNameValuesOrder(t *testing.T) {
+	tf, err := OpenRead("test-fixtures/acme.tea", reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	names := tf.GetOrderedNameValues().Names()
+	if !reflect.DeepEqual(names, []string{"url", "decimals"}) {
+		t.Fatalf("got names in wrong order: %v", names)
+	}
+
+	// Writing the same map twice must produce the same bytes
+	nameValues := map[string]interface{}{
+		"e": "e", "d": int32(4), "c": "c", "b": float64(2), "a": uint64(1),
+	}
+	var contents [][]byte
+	for i := 0; i < 2; i++ {
+		tf, err := Create("test.tea", WithNameValues(nameValues))
+		if err != nil {
+			t.Fatalf("error creating TeaFile: %v", err)
+		}
+		err = tf.Close()
+		if err != nil {
+			t.Fatalf("error closing TeaFile: %v", err)
+		}
+		content, err := ioutil.ReadFile("test.tea")
+		if err != nil {
+			t.Fatalf("error reading TeaFile: %v", err)
+		}
+		contents = append(contents, content)
+	}
+	if !bytes.Equal(contents[0], contents[1]) {
+		t.Fatalf("got different bytes for the same name values")
+	}
 	err = os.Remove("test.tea")
 	if err != nil {
 		t.Fatalf("error deleting TeaFile: %v", err)
@@ -105,6 +166,9 @@ func TestRead(t *testing.T) {
 	if err != nil {
 		t.Fatalf("error reading data: %v", err)
 	}
+	if err := tf.SeekItem(-1); err == nil {
+		t.Fatalf("was expecting an error seeking item -1")
+	}
 }
 
 
@@ -126,4 +190,227 @@ func TestMMapRead(t *testing.T) {
 		tmp = item.Volume
 	}
 	fmt.Println(tmp)
-}
\ No newline at end of file
+}
+
+func TestPackedLayout(t *testing.T) {
+	tf, err := Create(
+		"test.tea",
+		WithDataType(reflect.TypeOf(Data{})),
+		WithPackedLayout(),
+		WithTimeFields(719162, 86400000, []int32{0}))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	offsets := []int32{0, 8, 9, 17, 18}
+	for i, field := range tf.itemSection.Fields {
+		if field.Offset != offsets[i] {
+			t.Fatalf("got offset %d for %s, was expecting %d", field.Offset, field.Name, offsets[i])
+		}
+	}
+	if tf.itemSection.Info.ItemSize != 26 {
+		t.Fatalf("got item size %d, was expecting 26", tf.itemSection.Info.ItemSize)
+	}
+	err = tf.Write(data)
+	if err != nil {
+		t.Fatalf("error writing data to TeaFile: %v", err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	_, err = OpenRead("test.tea", reflect.TypeOf(Data{}))
+	if err == nil {
+		t.Fatalf("was expecting an error without explicit layout")
+	}
+
+	tf, err = OpenRead("test.tea", reflect.TypeOf(Data{}), WithExplicitLayout())
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	n, err := tf.ItemCount()
+	if err != nil || n != 1 {
+		t.Fatalf("got %d items, was expecting 1: %v", n, err)
+	}
+	val, err := tf.Read()
+	if err != nil {
+		t.Fatalf("error reading data: %v", err)
+	}
+	if item := val.(reflect.Value).Elem().Interface().(Data); item != data {
+		t.Fatalf("got %v, was expecting %v", item, data)
+	}
+	_, err = tf.OpenReadableMapping()
+	if err == nil {
+		t.Fatalf("was expecting an error memory mapping a packed file")
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	// The explicit layout of a file matching the data type takes the fast path
+	tf, err = OpenRead("test-fixtures/acme.tea", reflect.TypeOf(Data{}), WithExplicitLayout())
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	if tf.layout != nil {
+		t.Fatalf("was expecting identical layouts")
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+}
+
+func TestSchemaMismatch(t *testing.T) {
+	type Other struct {
+		Time   uint64
+		Price  uint16
+		Volume uint64
+		Prob   uint8
+		Prib   uint64
+		Extra  float64
+	}
+	_, err := OpenRead("test-fixtures/acme.tea", reflect.TypeOf(Other{}))
+	mismatch, ok := err.(*SchemaMismatchError)
+	if !ok {
+		t.Fatalf("was expecting a *SchemaMismatchError, got %v", err)
+	}
+	if mismatch.DataItemSize != 48 || mismatch.FileItemSize != 40 {
+		t.Fatalf("got wrong item sizes: %d %d", mismatch.DataItemSize, mismatch.FileItemSize)
+	}
+	if len(mismatch.Fields) != 2 {
+		t.Fatalf("was expecting 2 differing fields, got %v", mismatch)
+	}
+	price := mismatch.Fields[0]
+	if price.Index != 1 || price.DataType != "uint16" || price.FileType != FIELD_TYPE_UINT8 {
+		t.Fatalf("got wrong mismatch for Price: %v", price)
+	}
+	extra := mismatch.Fields[1]
+	if extra.Index != 5 || extra.DataName != "Extra" || extra.FileName != "" {
+		t.Fatalf("got wrong mismatch for Extra: %v", extra)
+	}
+
+	type Renamed struct {
+		Time   uint64
+		Price  uint8
+		Volume uint64
+		Prob   uint8
+		Prob2  uint64
+	}
+	_, err = OpenRead("test-fixtures/acme.tea", reflect.TypeOf(Renamed{}))
+	mismatch, ok = err.(*SchemaMismatchError)
+	if !ok || len(mismatch.Fields) != 1 || mismatch.Fields[0].FileName != "Prib" {
+		t.Fatalf("was expecting a mismatch on Prib, got %v", err)
+	}
+}
+
+func TestInMemory(t *testing.T) {
+	tf, err := CreateBuffer(
+		WithDataType(reflect.TypeOf(Data{})),
+		WithContentDescription("prices of acme at NYSE"),
+		WithOrderedNameValues(NameValues{
+			{Name: "url", Value: "www.acme.com"},
+			{Name: "decimals", Value: int32(2)},
+		}),
+		WithTimeFields(719162, 86400000, []int32{0}))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		err = tf.Write(data)
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	content := tf.Bytes()
+	goldBytes, err := ioutil.ReadFile("test-fixtures/acme.tea")
+	if err != nil {
+		t.Fatalf("error reading golden TeaFile: %v", err)
+	}
+	if !bytes.Equal(goldBytes, content) {
+		t.Fatalf("got different bytes than the golden TeaFile")
+	}
+
+	tf, err = OpenReadBytes(content, reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	r, err := tf.OpenReadableMapping()
+	if err != nil {
+		t.Fatalf("error mapping TeaFile: %v", err)
+	}
+	if r.Len() != 2 || *(*Data)(r.GetItem(1)) != data {
+		t.Fatalf("got wrong mapped items")
+	}
+
+	// The item count must not move the position of the reader
+	tf, err = NewReadSeeker(bytes.NewReader(content), reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	n, err := tf.ItemCount()
+	if err != nil || n != 2 {
+		t.Fatalf("got %d items, was expecting 2: %v", n, err)
+	}
+	val, err := tf.Read()
+	if err != nil {
+		t.Fatalf("error reading data: %v", err)
+	}
+	if item := val.(reflect.Value).Elem().Interface().(Data); item != data {
+		t.Fatalf("got %v, was expecting %v", item, data)
+	}
+	if _, err := tf.OpenReadableMapping(); err != ErrNotMappable {
+		t.Fatalf("was expecting ErrNotMappable, got %v", err)
+	}
+}
+
+func TestOpenWrite(t *testing.T) {
+	tf, err := Create("test.tea", WithDataType(reflect.TypeOf(Data{})))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	err = tf.Write(data)
+	if err != nil {
+		t.Fatalf("error writing data to TeaFile: %v", err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	tf, err = OpenWrite("test.tea", reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	err = tf.Write(data)
+	if err != nil {
+		t.Fatalf("error writing data to TeaFile: %v", err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+
+	tf, err = OpenRead("test.tea", reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	n, err := tf.ItemCount()
+	if err != nil || n != 2 {
+		t.Fatalf("got %d items, was expecting 2: %v", n, err)
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+}
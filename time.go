@@ -0,0 +1,107 @@
+package goteafiles
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"time"
+)
+
+// unixEpochDays is the number of days from 0001-01-01 to 1970-01-01, the
+// epoch used by most TeaFiles
+const unixEpochDays = 719162
+
+const nanosPerDay = 86400 * int64(time.Second)
+
+// Time returns the time of the given number of ticks since the epoch
+func (ts *TimeSection) Time(ticks int64) time.Time {
+	nanos := new(big.Int).Mul(big.NewInt(ticks), big.NewInt(nanosPerDay))
+	nanos.Div(nanos, big.NewInt(ts.TicksPerDay))
+	secs, nsec := new(big.Int).DivMod(nanos, big.NewInt(int64(time.Second)), new(big.Int))
+	epochSecs := (ts.Epoch - unixEpochDays) * 86400
+	return time.Unix(secs.Int64() + epochSecs, nsec.Int64()).UTC()
+}
+
+// Ticks returns the number of ticks from the epoch to t, rounded down
+func (ts *TimeSection) Ticks(t time.Time) int64 {
+	epochSecs := (ts.Epoch - unixEpochDays) * 86400
+	nanos := new(big.Int).Mul(big.NewInt(t.Unix() - epochSecs), big.NewInt(int64(time.Second)))
+	nanos.Add(nanos, big.NewInt(int64(t.Nanosecond())))
+	ticks := nanos.Mul(nanos, big.NewInt(ts.TicksPerDay))
+	ticks.Div(ticks, big.NewInt(nanosPerDay))
+	return ticks.Int64()
+}
+
+// timeFieldOffset returns the offset of the first time field, which must be
+// a 64-bit integer
+func (tf *TeaFile) timeFieldOffset() (int, error) {
+	if tf.timeSection == nil || len(tf.timeSection.Offsets) == 0 {
+		return 0, ErrNoTimeField
+	}
+	offset := tf.timeSection.Offsets[0]
+	for _, field := range tf.itemSection.Fields {
+		if field.Offset == offset {
+			if field.Type != FIELD_TYPE_INT64 && field.Type != FIELD_TYPE_UINT64 {
+				return 0, fmt.Errorf("time field %s is a %s", field.Name, FieldTypeName(field.Type))
+			}
+			if offset < 0 || offset + 8 > tf.itemSection.Info.ItemSize {
+				return 0, fmt.Errorf("time field %s at offset %d outside item of %d bytes", field.Name, offset, tf.itemSection.Info.ItemSize)
+			}
+			return int(offset), nil
+		}
+	}
+	return 0, fmt.Errorf("no field at time offset %d", offset)
+}
+
+// readItemAt reads the bytes of the item at index idx
+func (tf *TeaFile) readItemAt(idx int64, b []byte) error {
+	if tf.chunkReader != nil {
+		err := tf.chunkReader.seek(idx)
+		if err != nil { return err }
+		return tf.readItem(b)
+	}
+	_, err := tf.seeker.Seek(tf.header.ItemStart + idx * int64(len(b)), io.SeekStart)
+	if err != nil { return err }
+	tf.item = idx
+	return tf.readItem(b)
+}
+
+// SeekTime positions the file at the first item whose time is at or after
+// t, using a binary search on the first time field. Items must be sorted
+// by time. It returns the index of that item, which is the item count if
+// every item is before t.
+func (tf *TeaFile) SeekTime(t time.Time) (int64, error) {
+	if tf.mode == os.O_WRONLY {
+		return 0, fmt.Errorf("seeking in write mode: %w", ErrWrongMode)
+	}
+	if tf.itemSection == nil {
+		return 0, ErrNoItemSection
+	}
+	if tf.compression != NoCompression {
+		return 0, fmt.Errorf("seeking: %w", ErrCompressed)
+	}
+	if tf.seeker == nil {
+		return 0, ErrNotSeekable
+	}
+	offset, err := tf.timeFieldOffset()
+	if err != nil { return 0, err }
+	count, err := tf.ItemCount()
+	if err != nil { return 0, err }
+
+	target := tf.timeSection.Ticks(t)
+	b := make([]byte, tf.itemSection.Info.ItemSize)
+	lo, hi := int64(0), int64(count)
+	for lo < hi {
+		mid := lo + (hi - lo) / 2
+		err = tf.readItemAt(mid, b)
+		if err != nil { return 0, err }
+		ticks := int64(nativeEndian.Uint64(b[offset:]))
+		if ticks < target {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, tf.SeekItem(lo)
+}
@@ -0,0 +1,64 @@
+package goteafiles
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimeSection(t *testing.T) {
+	tf, err := OpenRead("test-fixtures/acme.tea", reflect.TypeOf(Data{}))
+	if err != nil {
+		t.Fatalf("error opening TeaFile: %v", err)
+	}
+	defer tf.Close()
+
+	at := time.Date(2011, 3, 4, 9, 0, 0, 0, time.UTC)
+	if tf.timeSection.Time(int64(data.Time)) != at {
+		t.Fatalf("got %v, was expecting %v", tf.timeSection.Time(int64(data.Time)), at)
+	}
+	if tf.timeSection.Ticks(at) != int64(data.Time) {
+		t.Fatalf("got %d ticks, was expecting %d", tf.timeSection.Ticks(at), data.Time)
+	}
+
+	// 100 nanosecond ticks from 0001-01-01, as used by .NET
+	ts := TimeSection{Epoch: 0, TicksPerDay: 864000000000}
+	ticks := ts.Ticks(at)
+	if ticks != 634348260000000000 || ts.Time(ticks) != at {
+		t.Fatalf("got wrong .NET ticks: %d", ticks)
+	}
+
+	idx, err := tf.SeekTime(at.Add(time.Millisecond))
+	if err != nil || idx != 2 {
+		t.Fatalf("got index %d, was expecting 2: %v", idx, err)
+	}
+}
+
+func TestTicksPerDay(t *testing.T) {
+	_, err := CreateBuffer(WithDataType(reflect.TypeOf(Tick{})), WithTimeFields(719162, 0, []int32{0}))
+	if err == nil {
+		t.Fatalf("was expecting an error for 0 ticks per day")
+	}
+
+	data, err := os.ReadFile("test-fixtures/acme.tea")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	var section bytes.Buffer
+	err = writeSection(&section, nativeEndian, &TimeSection{Epoch: 719162, TicksPerDay: 86400000, Count: 1, Offsets: []int32{0}})
+	if err != nil {
+		t.Fatalf("error encoding time section: %v", err)
+	}
+	offset := bytes.Index(data, section.Bytes())
+	if offset < 0 {
+		t.Fatalf("time section not found")
+	}
+	nativeEndian.PutUint64(data[offset + 16:], 0)
+	_, err = OpenReadBytes(data, nil)
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("was expecting ErrCorrupt, got %v", err)
+	}
+}
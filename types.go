@@ -0,0 +1,142 @@
+package goteafiles
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// Char is a UTF-16 code unit, the char type of .NET. TeaFiles written by
+// the C# library describe char fields as UInt16 fields.
+type Char uint16
+
+func (c Char) String() string {
+	return string(rune(c))
+}
+
+// Decimal is the 128-bit decimal type of .NET, described by the NetDecimal
+// field type. The value is (-1)^sign * (Hi:Mid:Lo) / 10^scale, where the
+// scale is stored in bits 16 to 23 of Flags and the sign in bit 31.
+type Decimal struct {
+	Flags uint32
+	Hi    uint32
+	Lo    uint32
+	Mid   uint32
+}
+
+var decimalType = reflect.TypeOf(Decimal{})
+
+// NewDecimal returns the decimal unscaled / 10^scale. The unscaled value
+// must fit in 96 bits and the scale must be between 0 and 28.
+func NewDecimal(unscaled *big.Int, scale int) (Decimal, error) {
+	if scale < 0 || scale > 28 {
+		return Decimal{}, fmt.Errorf("decimal scale %d out of range", scale)
+	}
+	abs := new(big.Int).Abs(unscaled)
+	if abs.BitLen() > 96 {
+		return Decimal{}, fmt.Errorf("decimal %v overflows 96 bits", unscaled)
+	}
+	mask := big.NewInt(math.MaxUint32)
+	d := Decimal{
+		Flags: uint32(scale) << 16,
+		Lo:    uint32(new(big.Int).And(abs, mask).Uint64()),
+		Mid:   uint32(new(big.Int).And(new(big.Int).Rsh(abs, 32), mask).Uint64()),
+		Hi:    uint32(new(big.Int).Rsh(abs, 64).Uint64()),
+	}
+	if unscaled.Sign() < 0 {
+		d.Flags |= 1 << 31
+	}
+	return d, nil
+}
+
+// ParseDecimal parses a decimal written as an optional sign, digits and
+// an optional fractional part
+func ParseDecimal(s string) (Decimal, error) {
+	str := s
+	negative := strings.HasPrefix(str, "-")
+	if negative || strings.HasPrefix(str, "+") {
+		str = str[1:]
+	}
+	scale := 0
+	if idx := strings.IndexByte(str, '.'); idx >= 0 {
+		scale = len(str) - idx - 1
+		str = str[:idx] + str[idx+1:]
+	}
+	unscaled, ok := new(big.Int).SetString(str, 10)
+	if !ok || strings.ContainsAny(str, "+-") {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if negative {
+		unscaled.Neg(unscaled)
+	}
+	return NewDecimal(unscaled, scale)
+}
+
+// Scale returns the number of decimal digits after the point
+func (d Decimal) Scale() int {
+	return int((d.Flags >> 16) & 0xff)
+}
+
+// Negative returns whether the sign bit is set
+func (d Decimal) Negative() bool {
+	return d.Flags & (1 << 31) != 0
+}
+
+// Unscaled returns the signed 96-bit integer holding the digits
+func (d Decimal) Unscaled() *big.Int {
+	v := new(big.Int).SetUint64(uint64(d.Hi))
+	v.Lsh(v, 32)
+	v.Or(v, new(big.Int).SetUint64(uint64(d.Mid)))
+	v.Lsh(v, 32)
+	v.Or(v, new(big.Int).SetUint64(uint64(d.Lo)))
+	if d.Negative() {
+		v.Neg(v)
+	}
+	return v
+}
+
+// Float64 returns the nearest float64 to the decimal
+func (d Decimal) Float64() float64 {
+	f, _ := new(big.Float).SetInt(d.Unscaled()).Float64()
+	return f / math.Pow10(d.Scale())
+}
+
+// String returns the exact decimal representation
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.Unscaled()).String()
+	scale := d.Scale()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale - len(digits) + 1) + digits
+		}
+		digits = digits[:len(digits) - scale] + "." + digits[len(digits) - scale:]
+	}
+	if d.Negative() {
+		digits = "-" + digits
+	}
+	return digits
+}
+
+// fieldTypeOf returns the field type describing values of the Go type
+func fieldTypeOf(typ reflect.Type) (int32, error) {
+	if typ == decimalType {
+		return FIELD_TYPE_NET_DECIMAL, nil
+	}
+	fieldType, ok := kindToFieldType[typ.Kind()]
+	if !ok {
+		return 0, fmt.Errorf("%w for field: %s", ErrUnsupportedType, typ)
+	}
+	return fieldType, nil
+}
+
+// fieldTypeMatches returns whether values of the Go type can be stored in
+// a field of the given field type
+func fieldTypeMatches(fieldType int32, typ reflect.Type) bool {
+	if fieldType == FIELD_TYPE_NET_DECIMAL {
+		return typ == decimalType
+	}
+	kind, ok := fieldTypeToKind[fieldType]
+	return ok && typ.Kind() == kind
+}
@@ -0,0 +1,37 @@
+package goteafiles
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	for _, str := range []string{"0", "1", "-1", "123.45", "-0.001", "79228162514264337593543950335"} {
+		d, err := ParseDecimal(str)
+		if err != nil {
+			t.Fatalf("error parsing decimal %s: %v", str, err)
+		}
+		if d.String() != str {
+			t.Fatalf("got %s, was expecting %s", d.String(), str)
+		}
+	}
+
+	// 1.5 as laid out by .NET
+	d := Decimal{Flags: 1 << 16, Lo: 15}
+	if d.Float64() != 1.5 {
+		t.Fatalf("got %v, was expecting 1.5", d.Float64())
+	}
+
+	for _, str := range []string{"1.2.3", "--5", "+-5", "-+5", "5-"} {
+		if _, err := ParseDecimal(str); err == nil {
+			t.Fatalf("was expecting an error for the invalid decimal %s", str)
+		}
+	}
+	if d, err := ParseDecimal("+5"); err != nil || d.String() != "5" {
+		t.Fatalf("got %v parsing +5: %v", d, err)
+	}
+	overflow := new(big.Int).Lsh(big.NewInt(1), 96)
+	if _, err := NewDecimal(overflow, 0); err == nil {
+		t.Fatalf("was expecting an error for an overflowing decimal")
+	}
+}
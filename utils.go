@@ -2,12 +2,12 @@ package goteafiles
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
 func readText(r io.Reader, order binary.ByteOrder) (string, error) {
-	var length int32
-	err := binary.Read(r, order, &length)
+	length, err := readCount(r, order, 1)
 	if err != nil { return "", err }
 	strBytes := make([]byte, length)
 	err = binary.Read(r, order, strBytes)
@@ -25,4 +25,49 @@ func writeText(w io.Writer, order binary.ByteOrder, text string) error {
 
 func textSize(text string) int64 {
 	return 4 + int64(len([]byte(text)))
-}
\ No newline at end of file
+}
+
+// countingReader counts the bytes read from the underlying reader. The
+// size of a section, when known, bounds the counts read by readCount.
+type countingReader struct {
+	r    io.Reader
+	n    int64
+	size int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// readCount reads a count of elements of elemSize bytes, checking that
+// they fit in the rest of the section when r reads a section
+func readCount(r io.Reader, order binary.ByteOrder, elemSize int64) (int, error) {
+	var count int32
+	err := binary.Read(r, order, &count)
+	if err != nil { return 0, err }
+	if count < 0 {
+		return 0, fmt.Errorf("negative count %d: %w", count, ErrCorrupt)
+	}
+	if cr, ok := r.(*countingReader); ok && cr.size > 0 && int64(count) * elemSize > cr.size - cr.n {
+		return 0, fmt.Errorf("%d elements of %d bytes in %d bytes: %w", count, elemSize, cr.size - cr.n, ErrCorrupt)
+	}
+	return int(count), nil
+}
+
+// truncated reports reaching the end of the file inside the header
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncatedHeader
+	}
+	return err
+}
+
+// unexpected reports reaching the end of the file where data is expected
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,252 @@
+package goteafiles
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+)
+
+// Problem is an inconsistency found by Validate. Offset is its position in
+// the file, or -1 when it has none.
+type Problem struct {
+	Offset  int64
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Offset < 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("offset %d: %s", p.Offset, p.Message)
+}
+
+// maxItemProblems is the number of problems of each kind reported for
+// items, further ones being counted only
+const maxItemProblems = 10
+
+// validator collects the problems of a file
+type validator struct {
+	problems []Problem
+	counts   map[string]int64
+}
+
+func (v *validator) report(offset int64, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Offset: offset, Message: fmt.Sprintf(format, args...)})
+}
+
+// reportItem reports a problem of the given kind, up to maxItemProblems
+// per kind
+func (v *validator) reportItem(kind string, offset int64, format string, args ...interface{}) {
+	v.counts[kind] += 1
+	if v.counts[kind] <= maxItemProblems {
+		v.report(offset, format, args...)
+	}
+}
+
+// Validate checks the file of the given size read from r: its magic
+// value, the sizes of its sections, the alignment of ItemStart, ItemEnd
+// against the file size, trailing partial items, the time ordering of
+// items, NaN and infinite floats, and checksums when the file has them.
+// It returns every problem found, an empty list meaning the file is
+// valid. Only a few problems of each kind are reported for items.
+func Validate(r io.ReaderAt, size int64) []Problem {
+	v := &validator{counts: make(map[string]int64)}
+	v.validate(r, size)
+	for kind, count := range v.counts {
+		if count > maxItemProblems {
+			v.report(-1, "%d more items %s", count - maxItemProblems, kind)
+		}
+	}
+	return v.problems
+}
+
+// ValidateFile validates the named file. Compressed files are
+// decompressed in memory first, offsets being those of the decompressed
+// file.
+func ValidateFile(name string) ([]Problem, error) {
+	f, err := os.Open(name)
+	if err != nil { return nil, err }
+	defer f.Close()
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(len(zstdMagic))
+	if c := sniffCompression(magic); c != NoCompression {
+		dr, err := c.newReader(br)
+		if err != nil { return nil, err }
+		defer dr.Close()
+		data, err := ioutil.ReadAll(dr)
+		if err != nil { return nil, err }
+		return Validate(bytes.NewReader(data), int64(len(data))), nil
+	}
+	fi, err := f.Stat()
+	if err != nil { return nil, err }
+	return Validate(f, fi.Size()), nil
+}
+
+func (v *validator) validate(r io.ReaderAt, size int64) {
+	var header Header
+	if size < headerSize {
+		v.report(0, "file of %d bytes is shorter than the %d bytes header", size, headerSize)
+		return
+	}
+	sr := io.NewSectionReader(r, 0, size)
+	err := binary.Read(sr, nativeEndian, &header)
+	if err != nil {
+		v.report(0, "reading header: %v", err)
+		return
+	}
+	if header.MagicValue != 0x0d0e0a0402080500 {
+		v.report(0, "%v: %#x", ErrBadMagic, uint64(header.MagicValue))
+		return
+	}
+
+	if header.ItemStart % 8 != 0 {
+		v.report(8, "ItemStart %d is not aligned on 8 bytes", header.ItemStart)
+	}
+	if header.ItemStart > size {
+		v.report(8, "ItemStart %d is after the end of the file at %d", header.ItemStart, size)
+		return
+	}
+	end := size
+	if header.ItemEnd != 0 {
+		if header.ItemEnd < header.ItemStart || header.ItemEnd > size {
+			v.report(16, "ItemEnd %d is outside the file, from ItemStart %d to %d", header.ItemEnd, header.ItemStart, size)
+			return
+		}
+		end = header.ItemEnd
+	}
+
+	// Sections, which cannot be located after an inconsistent one
+	tf := &TeaFile{header: header}
+	cr := &countingReader{r: sr, n: headerSize}
+	seen := make(map[int32]bool)
+	for i := 0; i < int(header.SectionCount); i++ {
+		offset := cr.n
+		s, err := readSection(cr, nativeEndian, offset)
+		if err != nil {
+			v.report(offset, "section %d: %v", i, err)
+			return
+		}
+		if seen[s.ID()] {
+			v.report(offset, "section %#x appears twice", s.ID())
+		}
+		seen[s.ID()] = true
+		kind, _ := findSectionKind(s.ID())
+		kind.set(tf, s)
+	}
+	if cr.n > header.ItemStart {
+		v.report(headerSize, "sections end at %d, after ItemStart %d", cr.n, header.ItemStart)
+		return
+	}
+	if tf.itemSection == nil {
+		v.report(-1, "%v", ErrNoItemSection)
+		return
+	}
+	itemSize := int64(tf.itemSection.Info.ItemSize)
+	if itemSize <= 0 || itemSize > maxItemSize {
+		v.report(-1, "invalid item size %d", itemSize)
+		return
+	}
+	for _, f := range tf.itemSection.Fields {
+		fieldSize, ok := fieldTypeSizes[f.Type]
+		if ok && (f.Offset < 0 || int64(f.Offset) + int64(fieldSize) > itemSize) {
+			v.report(-1, "field %s at offset %d is outside items of %d bytes", f.Name, f.Offset, itemSize)
+			return
+		}
+	}
+	// Counts that items are read with, the sections checking those they
+	// can, such as the ticks per day of the time section
+	if es := tf.encodingSection; es != nil && len(es.Encodings) != len(tf.itemSection.Fields) {
+		v.report(-1, "%d field encodings for %d fields", len(es.Encodings), len(tf.itemSection.Fields))
+		return
+	}
+	if cs := tf.chunkSection; cs != nil && cs.ChunkCount > 0 {
+		if cs.IndexOffset < header.ItemStart || cs.ChunkCount > (size - cs.IndexOffset) / 16 {
+			v.report(-1, "chunk index of %d chunks at offset %d is outside the file of %d bytes", cs.ChunkCount, cs.IndexOffset, size)
+			return
+		}
+	}
+	if tf.chunkSection == nil {
+		if rest := (end - header.ItemStart) % itemSize; rest != 0 {
+			v.report(end - rest, "trailing partial item of %d bytes", rest)
+		}
+	}
+	v.validateItems(r, size)
+}
+
+// validateItems reads every item, checking their time order and floats,
+// and verifies checksums
+func (v *validator) validateItems(r io.ReaderAt, size int64) {
+	tf, err := NewReader(r, size, nil)
+	if err != nil {
+		v.report(-1, "opening file: %v", err)
+		return
+	}
+	timeOffset, err := tf.timeFieldOffset()
+	if err != nil && !errors.Is(err, ErrNoTimeField) {
+		v.report(-1, "%v", err)
+	}
+	checkTime := err == nil
+
+	is := tf.itemSection
+	item := make([]byte, is.Info.ItemSize)
+	itemOffset := func(i int64) int64 {
+		if tf.chunkSection != nil {
+			return -1
+		}
+		return tf.header.ItemStart + i * int64(len(item))
+	}
+	var last int64
+	var i int64
+	for ; ; i++ {
+		err := tf.ReadBytes(item)
+		if err == io.EOF || (err == io.ErrUnexpectedEOF && tf.chunkSection == nil) {
+			// Partial items are reported with the file size
+			break
+		}
+		if err != nil {
+			v.report(itemOffset(i), "item %d: %v", i, err)
+			break
+		}
+		if checkTime {
+			ticks := int64(nativeEndian.Uint64(item[timeOffset:]))
+			if i > 0 && ticks < last {
+				v.reportItem("out of time order", itemOffset(i), "item %d at %v is before the previous item at %v",
+					i, tf.timeSection.Time(ticks), tf.timeSection.Time(last))
+			}
+			last = ticks
+		}
+		for _, f := range is.Fields {
+			var value float64
+			switch f.Type {
+			case FIELD_TYPE_FLOAT:
+				value = float64(math.Float32frombits(nativeEndian.Uint32(item[f.Offset:])))
+			case FIELD_TYPE_DOUBLE:
+				value = math.Float64frombits(nativeEndian.Uint64(item[f.Offset:]))
+			default:
+				continue
+			}
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				v.reportItem("with NaN or infinite floats", itemOffset(i), "item %d: field %s is %v", i, f.Name, value)
+			}
+		}
+	}
+	if tf.chunkSection != nil && i != tf.chunkSection.ItemCount {
+		v.report(-1, "read %d items, the chunk section counts %d", i, tf.chunkSection.ItemCount)
+	}
+
+	if tf.checksumSection != nil {
+		err := tf.Verify()
+		var cerr *ChecksumError
+		if errors.As(err, &cerr) {
+			v.report(cerr.Offset, "%v", err)
+		} else if err != nil {
+			v.report(-1, "verifying checksums: %v", err)
+		}
+	}
+}
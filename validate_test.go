@@ -0,0 +1,205 @@
+package goteafiles
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validateBytes(data []byte) []Problem {
+	return Validate(bytes.NewReader(data), int64(len(data)))
+}
+
+func TestValidate(t *testing.T) {
+	problems, err := ValidateFile("test-fixtures/acme.tea")
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("got problems %v: %v", problems, err)
+	}
+
+	tf, err := CreateBuffer(WithDataType(reflect.TypeOf(Tick{})), WithTimeFields(719162, 86400000, []int32{0}))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	ticks := []Tick{{0, 1}, {2, math.NaN()}, {1, 1}, {3, math.Inf(1)}}
+	for i := 0; i < 20; i++ {
+		ticks = append(ticks, Tick{int64(10 - i), 1})
+	}
+	for _, tick := range ticks {
+		err = tf.Write(tick)
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	data := append(tf.Bytes(), 1, 2, 3)
+	problems = validateBytes(data)
+	want := []string{
+		"item 1: field Price is NaN",
+		"item 2 at 1970-01-01 00:00:00.001 +0000 UTC is before the previous item",
+		"item 3: field Price is +Inf",
+		"trailing partial item of 3 bytes",
+		"10 more items out of time order",
+	}
+	for _, w := range want {
+		found := false
+		for _, p := range problems {
+			if strings.Contains(p.String(), w) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("problem %q not found in %v", w, problems)
+		}
+	}
+
+	valid := tickBuffer(t, []int64{0, 1}, []float64{1, 1}).Bytes()
+	corrupt := func(offset int, b byte) []byte {
+		c := append([]byte{}, valid...)
+		c[offset] = b
+		return c
+	}
+	cases := []struct {
+		data    []byte
+		problem string
+	}{
+		{valid[:20], "shorter than the 32 bytes header"},
+		{corrupt(0, 1), "byteordermark mismatch"},
+		{corrupt(8, 7), "ItemStart 7 is not aligned on 8 bytes"},
+		{corrupt(17, 1), "ItemEnd 256 is outside the file"},
+		{corrupt(36, 1), "section 0: section 0xa at offset 32"},
+	}
+	for _, c := range cases {
+		problems := validateBytes(c.data)
+		if len(problems) == 0 || !strings.Contains(problems[0].String(), c.problem) {
+			t.Fatalf("was expecting problem %q, got %v", c.problem, problems)
+		}
+	}
+}
+
+// sectionOffset returns the offset of the content of a section in data
+func sectionOffset(t *testing.T, data []byte, s Section) int {
+	var buf bytes.Buffer
+	err := writeSection(&buf, nativeEndian, s)
+	if err != nil {
+		t.Fatalf("error encoding section: %v", err)
+	}
+	offset := bytes.Index(data, buf.Bytes())
+	if offset < 0 {
+		t.Fatalf("section %#x not found", s.ID())
+	}
+	return offset + 8
+}
+
+func TestValidateCorruptSections(t *testing.T) {
+	// Out of order items with no ticks per day
+	tf := tickBuffer(t, []int64{1, 0}, []float64{1, 1})
+	data := append([]byte{}, tf.Bytes()...)
+	nativeEndian.PutUint64(data[sectionOffset(t, data, tf.timeSection) + 8:], 0)
+	problems := validateBytes(data)
+	if len(problems) != 1 || !strings.Contains(problems[0].String(), "0 ticks per day") {
+		t.Fatalf("was expecting a time section problem, got %v", problems)
+	}
+
+	data = append([]byte{}, tf.Bytes()...)
+	nativeEndian.PutUint32(data[sectionOffset(t, data, tf.itemSection):], 0)
+	problems = validateBytes(data)
+	if len(problems) != 1 || !strings.Contains(problems[0].String(), "invalid item size 0") {
+		t.Fatalf("was expecting an item size problem, got %v", problems)
+	}
+
+	tf, err := Create(
+		"test.tea",
+		WithDataType(reflect.TypeOf(Tick{})),
+		WithChunkCompression(Zstd, 10),
+		WithDeltaEncoding(nil))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		err = tf.Write(Tick{int64(i), 1})
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	valid, err := os.ReadFile("test.tea")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+
+	// Chunk and encoding counts far beyond the file
+	data = append([]byte{}, valid...)
+	offset := sectionOffset(t, data, tf.chunkSection)
+	nativeEndian.PutUint64(data[offset + 8:], 10 << 40)
+	nativeEndian.PutUint64(data[offset + 16:], 1 << 40)
+	problems = validateBytes(data)
+	if len(problems) != 1 || !strings.Contains(problems[0].String(), "chunk index of 1099511627776 chunks") {
+		t.Fatalf("was expecting a chunk index problem, got %v", problems)
+	}
+	nativeEndian.PutUint64(data[offset + 8:], 1 << 40)
+	problems = validateBytes(data)
+	if len(problems) != 1 || !strings.Contains(problems[0].String(), "corrupt file") {
+		t.Fatalf("was expecting a chunk section problem, got %v", problems)
+	}
+	data = append([]byte{}, valid...)
+	nativeEndian.PutUint64(data[offset + 24:], 1 << 40)
+	problems = validateBytes(data)
+	if len(problems) != 1 || !strings.Contains(problems[0].String(), "chunk index of 10 chunks at offset 1099511627776") {
+		t.Fatalf("was expecting a chunk index problem, got %v", problems)
+	}
+	data = append([]byte{}, valid...)
+	nativeEndian.PutUint32(data[sectionOffset(t, data, tf.encodingSection):], 1 << 30)
+	problems = validateBytes(data)
+	if len(problems) != 1 || !strings.Contains(problems[0].String(), "corrupt file") {
+		t.Fatalf("was expecting an encoding section problem, got %v", problems)
+	}
+}
+
+func TestValidateChecksums(t *testing.T) {
+	tf, err := Create(
+		"test.tea",
+		WithDataType(reflect.TypeOf(Tick{})),
+		WithTimeFields(719162, 86400000, []int32{0}),
+		WithChunkCompression(Gzip, 10),
+		WithChecksums(64))
+	if err != nil {
+		t.Fatalf("error creating TeaFile: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		err = tf.Write(Tick{int64(i), 1})
+		if err != nil {
+			t.Fatalf("error writing data to TeaFile: %v", err)
+		}
+	}
+	err = tf.Close()
+	if err != nil {
+		t.Fatalf("error closing TeaFile: %v", err)
+	}
+	problems, err := ValidateFile("test.tea")
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("got problems %v: %v", problems, err)
+	}
+
+	data, err := os.ReadFile("test.tea")
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	data[len(data) - 20] ^= 1
+	problems = validateBytes(data)
+	if len(problems) == 0 || !strings.Contains(problems[len(problems) - 1].String(), "checksum mismatch") {
+		t.Fatalf("was expecting a checksum mismatch, got %v", problems)
+	}
+	err = os.Remove("test.tea")
+	if err != nil {
+		t.Fatalf("error deleting TeaFile: %v", err)
+	}
+}